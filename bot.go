@@ -0,0 +1,464 @@
+package main
+
+import (
+	"math/rand"
+)
+
+// Bots see bodies and other players this many cells away.
+const BOT_SIGHT = 16
+
+// Crewmates within this distance of a target would see an impostor kill.
+const BOT_WITNESS_RADIUS = 12
+
+// Bots stay this far away from players they suspect.
+const BOT_AVOID_RADIUS = 6
+
+// Bots stop following a player at this distance.
+const BOT_FOLLOW_RADIUS = 4
+
+// Suspicion of the players near a body when a bot finds it,
+// and of the players next to it if a bot sees it appear.
+const BOT_SUSPICION_NEAR = 2
+const BOT_SUSPICION_WITNESSED = 10
+
+// Bots vote for, and avoid, players suspected at least this much.
+const BOT_SUSPECT = 4
+
+// Number of ticks after a meeting is called before a bot votes,
+// plus up to as many ticks again at random.
+const BOT_VOTE_DELAY = 40
+
+// Number of ticks an impostor bot tries to reach a vent after a kill.
+const BOT_FLEE_TICKS = 200
+
+// Finds paths on a map, reusing its buffers between searches.
+type navigator struct {
+	m       *Map
+	visited []uint32
+	parent  []int32
+	queue   []int32
+	stamp   uint32
+}
+
+func newNavigator(m *Map) *navigator {
+	return &navigator{
+		m:       m,
+		visited: make([]uint32, len(m.Data)),
+		parent:  make([]int32, len(m.Data)),
+	}
+}
+
+// Appends the cells of a shortest path over the floor from one
+// cell to another to path, starting with the first cell, or
+// returns false if there is none.
+func (n *navigator) find(path []int32, from int, to int) ([]int32, bool) {
+	w := int(n.m.Width)
+	if from == to {
+		return append(path, int32(to)), true
+	}
+
+	// Searches from the destination, so that the search
+	// ends as soon as it reaches the player.
+	n.stamp++
+	n.queue = append(n.queue[:0], int32(to))
+	n.visited[to] = n.stamp
+	for i := 0; i < len(n.queue); i++ {
+		c := int(n.queue[i])
+		// Horizontal steps come first, since players
+		// move vertically only every other tick.
+		for _, d := range [4][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			x, y := c%w+d[0], c/w+d[1]
+			if x < 0 || x >= w || y < 0 || y >= int(n.m.Height()) {
+				continue
+			}
+			next := y*w + x
+			if n.visited[next] == n.stamp || next != from && n.m.Data[next] != ' ' {
+				continue
+			}
+			n.visited[next] = n.stamp
+			n.parent[next] = int32(c)
+			if next == from {
+				path = append(path, int32(from))
+				for cell := from; cell != to; {
+					cell = int(n.parent[cell])
+					path = append(path, int32(cell))
+				}
+				return path, true
+			}
+			n.queue = append(n.queue, int32(next))
+		}
+	}
+	return path, false
+}
+
+func sign(n int) int8 {
+	if n < 0 {
+		return -1
+	} else if n > 0 {
+		return 1
+	}
+	return 0
+}
+
+// Returns the direction of the longer side
+// of the line from one cell to another.
+func straight(fromX uint32, fromY uint32, toX uint32, toY uint32) [2]int8 {
+	dx, dy := int(toX)-int(fromX), int(toY)-int(fromY)
+	if dx*dx >= dy*dy {
+		return [2]int8{sign(dx), 0}
+	}
+	return [2]int8{0, sign(dy)}
+}
+
+// Plays the game in place of a client. A bot only uses what
+// its player could see: where the other players and bodies
+// near it are, and its own role and tasks.
+type Bot struct {
+	Player int
+
+	nav  *navigator
+	rand *rand.Rand
+
+	// Station that the bot walks to while
+	// it has nothing else to do, or -1.
+	goal int
+
+	// How much the bot suspects each player
+	suspicion []int
+	// Bodies that the bot has found, and the
+	// bodies that were on the map last tick.
+	found  []bool
+	bodies []bool
+
+	// Tick at which the bot votes during a meeting.
+	voteAt uint
+	voted  bool
+
+	// Ticks left to reach a vent after a kill.
+	flee int
+
+	// Path that the bot follows, starting with the cell it is
+	// in, until it moves off it or its destination changes.
+	path []int32
+}
+
+func NewBot(g *Game, playerIdx int, nav *navigator) *Bot {
+	return &Bot{
+		Player:    playerIdx,
+		nav:       nav,
+		rand:      rand.New(rand.NewSource(g.Seed + int64(playerIdx))),
+		goal:      -1,
+		suspicion: make([]int, len(g.Players)),
+		found:     make([]bool, len(g.Players)),
+		bodies:    make([]bool, len(g.Players)),
+	}
+}
+
+// Prepares the bot to vote when a meeting is called.
+func (b *Bot) HandleEvent(ev Event) {
+	switch ev.Type {
+	case EVENT_MEETING_CALLED:
+		b.voteAt = ev.Tick + BOT_VOTE_DELAY + uint(b.rand.Intn(BOT_VOTE_DELAY))
+		b.voted = false
+		for i := range b.found {
+			b.found[i] = false
+		}
+	case EVENT_PLAYER_KILLED:
+		if ev.Player == b.Player {
+			b.flee = BOT_FLEE_TICKS
+		}
+	}
+}
+
+// Returns the commands of the bot for the next tick.
+// Memory safety: The game must not change meanwhile.
+func (b *Bot) Think(g *Game) []Command {
+	me := &g.Players[b.Player]
+	if me.Disconnected || g.Phase == PHASE_OVER {
+		return nil
+	}
+	b.observe(g)
+
+	if g.Phase == PHASE_MEETING {
+		return b.vote(g)
+	}
+	if me.Imposter {
+		return b.impostor(g)
+	}
+	return b.crewmate(g)
+}
+
+// Suspects the players in sight who are near bodies that the bot finds.
+func (b *Bot) observe(g *Game) {
+	me := &g.Players[b.Player]
+	for i, p := range g.Players {
+		appeared := p.Body && !b.bodies[i]
+		b.bodies[i] = p.Body
+		if !p.Body || b.found[i] || distance2(p.Corpse[0], p.Corpse[1], me.X, me.Y) > BOT_SIGHT*BOT_SIGHT {
+			continue
+		}
+		b.found[i] = true
+
+		for j, suspect := range g.Players {
+			if j == b.Player || suspect.Dead || !b.sees(g, suspect.X, suspect.Y) {
+				continue
+			}
+			d := distance2(p.Corpse[0], p.Corpse[1], suspect.X, suspect.Y)
+			if appeared && d <= (KILL_RADIUS+1)*(KILL_RADIUS+1) {
+				b.suspicion[j] += BOT_SUSPICION_WITNESSED
+			} else if d <= BOT_SIGHT*BOT_SIGHT {
+				b.suspicion[j] += BOT_SUSPICION_NEAR
+			}
+		}
+	}
+}
+
+// Returns the living player that the bot suspects most, leaving
+// out impostors if the bot is one, or -1 if it suspects no one.
+func (b *Bot) suspect(g *Game) int {
+	me := &g.Players[b.Player]
+	suspect, most := -1, BOT_SUSPECT-1
+	for i, p := range g.Players {
+		if i == b.Player || p.Dead || me.Imposter && p.Imposter {
+			continue
+		}
+		if b.suspicion[i] > most {
+			suspect, most = i, b.suspicion[i]
+		}
+	}
+	return suspect
+}
+
+func (b *Bot) vote(g *Game) []Command {
+	if b.voted || g.Tick < b.voteAt || g.Players[b.Player].Dead {
+		return nil
+	}
+	b.voted = true
+
+	target := b.suspect(g)
+	if target < 0 {
+		target = SKIP_VOTE
+	}
+	return []Command{{Player: b.Player, Type: CMD_VOTE, Target: target}}
+}
+
+// Returns the command to start moving in a direction,
+// if the bot is not moving in it already.
+func (b *Bot) move(g *Game, direction [2]int8) []Command {
+	if g.Players[b.Player].Direction == direction {
+		return nil
+	}
+	return []Command{{Player: b.Player, Type: CMD_MOVE, Direction: direction}}
+}
+
+// Returns true if nothing stops the bot from stepping in a direction.
+func (b *Bot) free(g *Game, direction [2]int8) bool {
+	me := &g.Players[b.Player]
+	x, y := int(me.X)+int(direction[0]), int(me.Y)+int(direction[1])
+	return me.Dead || g.Map.Data[y*int(g.Map.Width)+x] == ' '
+}
+
+// Returns true if the bot is near enough to a cell to see it.
+func (b *Bot) sees(g *Game, x uint32, y uint32) bool {
+	me := &g.Players[b.Player]
+	return distance2(x, y, me.X, me.Y) <= BOT_SIGHT*BOT_SIGHT
+}
+
+// Moves towards a cell. Returns false if it cannot be reached.
+// Ghosts walk through walls, so they go straight.
+func (b *Bot) moveTo(g *Game, x uint32, y uint32) ([]Command, bool) {
+	me := &g.Players[b.Player]
+	if me.Dead {
+		return b.move(g, straight(me.X, me.Y, x, y)), true
+	}
+
+	w := int(g.Map.Width)
+	from, to := int(me.Y)*w+int(me.X), int(y)*w+int(x)
+	// Players move at most one cell per tick, so the bot is
+	// either still in the first cell of its path or in the next.
+	if len(b.path) > 1 && int(b.path[1]) == from {
+		b.path = b.path[1:]
+	}
+	if len(b.path) == 0 || int(b.path[0]) != from || int(b.path[len(b.path)-1]) != to {
+		var ok bool
+		b.path, ok = b.nav.find(b.path[:0], from, to)
+		if !ok {
+			return b.move(g, [2]int8{}), false
+		}
+	}
+
+	if len(b.path) == 1 {
+		return b.move(g, [2]int8{}), true
+	}
+	next := int(b.path[1])
+	return b.move(g, [2]int8{int8(next%w - from%w), int8(next/w - from/w)}), true
+}
+
+// Walks between stations at random.
+func (b *Bot) wander(g *Game) []Command {
+	me := &g.Players[b.Player]
+	if b.goal >= 0 {
+		station := g.Map.Stations[b.goal]
+		if distance2(station.X, station.Y, me.X, me.Y) <= USE_RADIUS*USE_RADIUS {
+			b.goal = -1
+		}
+	}
+	if b.goal < 0 {
+		b.goal = b.rand.Intn(len(g.Map.Stations))
+	}
+
+	station := g.Map.Stations[b.goal]
+	commands, ok := b.moveTo(g, station.X, station.Y+1)
+	if !ok {
+		b.goal = -1
+	}
+	return commands
+}
+
+func (b *Bot) crewmate(g *Game) []Command {
+	me := &g.Players[b.Player]
+
+	if !me.Dead {
+		// Reports the nearest body that it sees.
+		body, nearest := -1, BOT_SIGHT*BOT_SIGHT+1
+		for i, p := range g.Players {
+			if d := distance2(p.Corpse[0], p.Corpse[1], me.X, me.Y); p.Body && d < nearest {
+				body, nearest = i, d
+			}
+		}
+		if body >= 0 {
+			p := &g.Players[body]
+			if nearest <= REPORT_RADIUS*REPORT_RADIUS {
+				return []Command{{Player: b.Player, Type: CMD_REPORT}}
+			}
+			if commands, ok := b.moveTo(g, p.Corpse[0], p.Corpse[1]); ok {
+				return commands
+			}
+		}
+
+		// Keeps away from suspects.
+		for i, p := range g.Players {
+			if i == b.Player || p.Dead || b.suspicion[i] < BOT_SUSPECT {
+				continue
+			}
+			if distance2(p.X, p.Y, me.X, me.Y) <= BOT_AVOID_RADIUS*BOT_AVOID_RADIUS {
+				away := straight(p.X, p.Y, me.X, me.Y)
+				for _, direction := range [3][2]int8{away, {away[1], away[0]}, {-away[1], -away[0]}} {
+					if direction != [2]int8{} && b.free(g, direction) {
+						return b.move(g, direction)
+					}
+				}
+			}
+		}
+	}
+
+	if me.ActiveTask >= 0 {
+		return nil
+	}
+	for _, task := range me.Tasks {
+		if task.Done {
+			continue
+		}
+		station := g.Map.Stations[task.Station]
+		if distance2(station.X, station.Y, me.X, me.Y) <= USE_RADIUS*USE_RADIUS {
+			return []Command{{Player: b.Player, Type: CMD_TASK}}
+		}
+		if commands, ok := b.moveTo(g, station.X, station.Y+1); ok {
+			return commands
+		}
+	}
+
+	if !me.Dead {
+		// With nothing left to do, sticks with the nearest
+		// player that it trusts, for safety in numbers.
+		buddy, nearest := -1, BOT_SIGHT*BOT_SIGHT+1
+		for i, p := range g.Players {
+			if i == b.Player || p.Dead || b.suspicion[i] >= BOT_SUSPECT {
+				continue
+			}
+			if d := distance2(p.X, p.Y, me.X, me.Y); d < nearest {
+				buddy, nearest = i, d
+			}
+		}
+		if buddy >= 0 {
+			if nearest <= BOT_FOLLOW_RADIUS*BOT_FOLLOW_RADIUS {
+				return b.move(g, [2]int8{})
+			}
+			p := &g.Players[buddy]
+			if commands, ok := b.moveTo(g, p.X, p.Y); ok {
+				return commands
+			}
+		}
+	}
+	return b.wander(g)
+}
+
+// Returns true if no crewmate other than target that the bot
+// sees is near enough to the given cell to see what happens there.
+func (b *Bot) unwatched(g *Game, target int, x uint32, y uint32) bool {
+	for i, p := range g.Players {
+		if i == b.Player || i == target || p.Dead || p.Imposter || !b.sees(g, p.X, p.Y) {
+			continue
+		}
+		if distance2(p.X, p.Y, x, y) <= BOT_WITNESS_RADIUS*BOT_WITNESS_RADIUS {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Bot) impostor(g *Game) []Command {
+	me := &g.Players[b.Player]
+	if me.Dead {
+		return nil
+	}
+
+	if b.flee > 0 {
+		b.flee--
+		vent, nearest := -1, 0
+		for i, station := range g.Map.Stations {
+			if d := distance2(station.X, station.Y, me.X, me.Y); station.Name == VENT && (vent < 0 || d < nearest) {
+				vent, nearest = i, d
+			}
+		}
+		if vent >= 0 {
+			if nearest <= USE_RADIUS*USE_RADIUS && b.unwatched(g, -1, me.X, me.Y) {
+				b.flee = 0
+				b.goal = -1
+				return []Command{{Player: b.Player, Type: CMD_VENT}}
+			}
+			station := g.Map.Stations[vent]
+			if commands, ok := b.moveTo(g, station.X, station.Y+1); ok {
+				return commands
+			}
+		}
+	}
+
+	// Stalks the nearest crewmate in sight that is alone.
+	target, nearest := -1, 0
+	for i, p := range g.Players {
+		if p.Dead || p.Imposter || !b.sees(g, p.X, p.Y) || !b.unwatched(g, i, p.X, p.Y) {
+			continue
+		}
+		if d := distance2(p.X, p.Y, me.X, me.Y); target < 0 || d < nearest {
+			target, nearest = i, d
+		}
+	}
+	if target >= 0 {
+		if nearest <= KILL_RADIUS*KILL_RADIUS && me.KillCooldown == 0 {
+			return []Command{{Player: b.Player, Type: CMD_KILL}}
+		}
+		p := &g.Players[target]
+		if nearest > BOT_FOLLOW_RADIUS*BOT_FOLLOW_RADIUS || me.KillCooldown == 0 {
+			if commands, ok := b.moveTo(g, p.X, p.Y); ok {
+				return commands
+			}
+		} else {
+			// Waits nearby for the cooldown.
+			return b.move(g, [2]int8{})
+		}
+	}
+
+	// Pretends to do tasks.
+	return b.wander(g)
+}
@@ -1,18 +1,29 @@
 package main
 
 import (
+	"errors"
 	"math/rand"
-
-	"github.com/allen-b1/sus-tux/nui"
+	"time"
 )
 
-type TaskState interface {
-	Widgets() []nui.Widget
-}
+// Time between ticks of a game
+const TICK_DURATION = 50 * time.Millisecond
+
+// Distances are in cells of the map.
+const USE_RADIUS = 3
+const REPORT_RADIUS = 6
 
-type Task interface {
-	CreateState() TaskState
-	Description() string
+// Durations are in ticks.
+const KILL_COOLDOWN = 400
+const TASK_TICKS = 60
+const MEETING_TICKS = 1200
+
+const TASKS_PER_PLAYER = 3
+
+// A task that a crewmate has to do at a station.
+type GameTask struct {
+	Station int
+	Done    bool
 }
 
 // State relating to a player
@@ -24,13 +35,23 @@ type GamePlayer struct {
 	Disconnected bool
 	Imposter     bool
 
+	// Set while the player's corpse lies on the map,
+	// until it is reported.
+	Body bool
+
 	// Horizontal direction corresponds to index 0.
 	// Vertical direction corresponds to index 1.
 	// Direction[x] in {-1, 0, +1}.
 	Direction [2]int8
 
-	OpenTask TaskState
-	Tasks    []Task
+	Tasks []GameTask
+	// Index of the task that the player is doing, or -1,
+	// and the number of ticks they have been doing it for.
+	ActiveTask   int
+	TaskProgress int
+
+	// Number of ticks until an impostor can kill.
+	KillCooldown int
 }
 
 func (p *GamePlayer) UpdatePositionX(width uint32) uint32 {
@@ -54,37 +75,586 @@ func (p *GamePlayer) UpdatePositionY(height uint32) uint32 {
 	return uint32(y)
 }
 
+// Returns the number of tasks that the player has done.
+func (p *GamePlayer) TasksDone() int {
+	n := 0
+	for _, task := range p.Tasks {
+		if task.Done {
+			n++
+		}
+	}
+	return n
+}
+
+// Returns the square of the distance between two cells.
+func distance2(x1 uint32, y1 uint32, x2 uint32, y2 uint32) int {
+	dx, dy := int(x1)-int(x2), int(y1)-int(y2)
+	return dx*dx + dy*dy
+}
+
+type CommandType uint8
+
+const (
+	// Sets the direction that the player moves in.
+	CMD_MOVE CommandType = iota
+	// Kills the nearest player, if the player is an impostor.
+	CMD_KILL
+	// Removes a player who disconnected from the game.
+	CMD_LEAVE
+	// Reports a nearby corpse, which calls a meeting.
+	CMD_REPORT
+	// Votes for the player Target during a meeting, or -1 to skip.
+	CMD_VOTE
+	// Moves an impostor to the next vent.
+	CMD_VENT
+	// Starts a nearby task.
+	CMD_TASK
+)
+
+// Something a player does during a tick.
+type Command struct {
+	Player int
+	Type   CommandType
+
+	// Only for CMD_MOVE
+	Direction [2]int8
+	// Only for CMD_VOTE
+	Target int
+}
+
+type EventType uint8
+
+const (
+	// Player killed Target.
+	EVENT_PLAYER_KILLED EventType = iota
+	// Player left the game.
+	EVENT_PLAYER_LEFT
+	// Player reported the corpse of Target.
+	EVENT_MEETING_CALLED
+	// Player voted for Target, or skipped if it is -1.
+	EVENT_VOTE_CAST
+	// The meeting ended and Target was ejected, or no one if it is -1.
+	EVENT_MEETING_ENDED
+	// Player went through a vent.
+	EVENT_PLAYER_VENTED
+	// Player completed their task with the index Target.
+	EVENT_TASK_COMPLETED
+	// The game ended, see ImpostorsWin.
+	EVENT_GAME_OVER
+)
+
+// Something that happened during a tick.
+type Event struct {
+	Type   EventType
+	Tick   uint
+	Player int
+	Target int
+
+	// Only for EVENT_GAME_OVER
+	ImpostorsWin bool
+}
+
+type Phase uint8
+
+const (
+	PHASE_PLAYING Phase = iota
+	PHASE_MEETING
+	PHASE_OVER
+)
+
+// Reasons that commands are rejected
+var (
+	ErrInvalidPlayer = errors.New("no such player")
+	ErrInvalidMove   = errors.New("invalid direction")
+	ErrDead          = errors.New("player is dead")
+	ErrNotImpostor   = errors.New("player is not an impostor")
+	ErrImpostor      = errors.New("player is an impostor")
+	ErrCooldown      = errors.New("kill is on cooldown")
+	ErrNothingNear   = errors.New("nothing is near enough")
+	ErrPhase         = errors.New("not possible at this point of the game")
+	ErrAlreadyVoted  = errors.New("player already voted")
+)
+
+// Votes of players during a meeting
+const (
+	NO_VOTE   = -2
+	SKIP_VOTE = -1
+)
+
+type Meeting struct {
+	Reporter int
+	Corpse   int
+	// Vote of each player: the index of a
+	// player, SKIP_VOTE or NO_VOTE.
+	Votes []int
+	// Tick that the meeting ends at, if not everyone votes.
+	End uint
+}
+
+// A game is only changed by its commands, which are applied in Step,
+// and all randomness comes from its seed, so the same seed and commands
+// always result in the same game. The rules are enforced here: commands
+// that are not allowed return an error and do nothing.
 type Game struct {
 	Map     *Map
 	Players []GamePlayer
+
+	Seed int64
+	// Number of ticks that have been stepped.
+	Tick uint
+
+	Phase Phase
+	// Set during PHASE_MEETING
+	Meeting *Meeting
+
+	rand        *rand.Rand
+	subscribers []func(Event)
 }
 
-func NewGame(nplayers int, map_ *Map) *Game {
-	imposter := rand.Intn(nplayers)
+func NewGame(nplayers int, map_ *Map, seed int64) *Game {
+	rng := rand.New(rand.NewSource(seed))
+	imposter := rng.Intn(nplayers)
+
+	var taskStations []int
+	for i, station := range map_.Stations {
+		if station.Name != VENT {
+			taskStations = append(taskStations, i)
+		}
+	}
 
 	players := make([]GamePlayer, nplayers)
 	for i := range players {
-		// TODO: Randomize & initialize tasks
-
 		players[i] = GamePlayer{
-			X: map_.Width / 2,
-			Y: map_.Height() / 2,
+			X:            map_.Width / 2,
+			Y:            map_.Height() / 2,
+			ActiveTask:   -1,
+			KillCooldown: KILL_COOLDOWN,
+		}
+		if i == imposter {
+			players[i].Imposter = true
+			continue
 		}
-	}
 
-	players[imposter].Imposter = true
+		order := rng.Perm(len(taskStations))
+		for j := 0; j < TASKS_PER_PLAYER && j < len(order); j++ {
+			players[i].Tasks = append(players[i].Tasks, GameTask{Station: taskStations[order[j]]})
+		}
+	}
 
 	return &Game{
 		Map:     map_,
 		Players: players,
+		Seed:    seed,
+		rand:    rng,
+	}
+}
+
+// The state of a game after a tick. It is never changed, so
+// it can be read without locking while the game goes on.
+type GameSnapshot struct {
+	Tick    uint
+	Phase   Phase
+	Players []GamePlayer
+}
+
+func (g *Game) Snapshot() *GameSnapshot {
+	players := make([]GamePlayer, len(g.Players))
+	copy(players, g.Players)
+	for i := range players {
+		players[i].Tasks = append([]GameTask(nil), players[i].Tasks...)
+	}
+	return &GameSnapshot{Tick: g.Tick, Phase: g.Phase, Players: players}
+}
+
+// Calls handler with every event, in order, as it happens.
+// Events happen during commands and Step.
+func (g *Game) Subscribe(handler func(Event)) {
+	g.subscribers = append(g.subscribers, handler)
+}
+
+func (g *Game) emit(ev Event) {
+	ev.Tick = g.Tick
+	for _, handler := range g.subscribers {
+		handler(ev)
+	}
+}
+
+// Advances the game by one tick, after applying
+// the commands sent during it in order.
+func (g *Game) Step(commands []Command) {
+	if g.Tick == 0 {
+		// A game can be won before anything happens,
+		// such as by an impostor without any crewmates.
+		g.checkOver()
+	}
+	for _, cmd := range commands {
+		g.Do(cmd)
+	}
+	g.update()
+	g.Tick++
+}
+
+// Applies a command.
+func (g *Game) Do(cmd Command) error {
+	switch cmd.Type {
+	case CMD_MOVE:
+		return g.Move(cmd.Player, cmd.Direction)
+	case CMD_KILL:
+		return g.Kill(cmd.Player)
+	case CMD_LEAVE:
+		return g.Leave(cmd.Player)
+	case CMD_REPORT:
+		return g.Report(cmd.Player)
+	case CMD_VOTE:
+		return g.Vote(cmd.Player, cmd.Target)
+	case CMD_VENT:
+		return g.UseVent(cmd.Player)
+	case CMD_TASK:
+		return g.StartTask(cmd.Player)
+	}
+	return errors.New("unknown command")
+}
+
+// Returns a player who is still in the game.
+func (g *Game) player(playerIdx int) (*GamePlayer, error) {
+	if playerIdx < 0 || playerIdx >= len(g.Players) || g.Players[playerIdx].Disconnected {
+		return nil, ErrInvalidPlayer
+	}
+	return &g.Players[playerIdx], nil
+}
+
+// Returns a player who is still alive.
+func (g *Game) living(playerIdx int) (*GamePlayer, error) {
+	player, err := g.player(playerIdx)
+	if err != nil {
+		return nil, err
+	}
+	if player.Dead {
+		return nil, ErrDead
+	}
+	return player, nil
+}
+
+// Returns a living player while the game is being played.
+func (g *Game) playing(playerIdx int) (*GamePlayer, error) {
+	player, err := g.living(playerIdx)
+	if err != nil {
+		return nil, err
+	}
+	if g.Phase != PHASE_PLAYING {
+		return nil, ErrPhase
+	}
+	return player, nil
+}
+
+// Sets the direction that a player moves in, which stops their task.
+// Dead players keep moving around as ghosts.
+func (g *Game) Move(playerIdx int, direction [2]int8) error {
+	player, err := g.player(playerIdx)
+	if err != nil {
+		return err
+	}
+	if g.Phase != PHASE_PLAYING {
+		return ErrPhase
+	}
+	for _, d := range direction {
+		if d < -1 || d > 1 {
+			return ErrInvalidMove
+		}
+	}
+	player.Direction = direction
+	if direction != [2]int8{} {
+		player.ActiveTask = -1
+	}
+	return nil
+}
+
+// Kills the nearest crewmate within KILL_RADIUS of an impostor.
+func (g *Game) Kill(playerIdx int) error {
+	player, err := g.playing(playerIdx)
+	if err != nil {
+		return err
+	}
+	if !player.Imposter {
+		return ErrNotImpostor
+	}
+	if player.KillCooldown > 0 {
+		return ErrCooldown
+	}
+
+	target := -1
+	nearest := KILL_RADIUS*KILL_RADIUS + 1
+	for i, p := range g.Players {
+		if p.Dead || p.Imposter {
+			continue
+		}
+		if d := distance2(p.X, p.Y, player.X, player.Y); d < nearest {
+			target, nearest = i, d
+		}
+	}
+	if target < 0 {
+		return ErrNothingNear
+	}
+
+	g.die(target)
+	g.Players[target].Body = true
+	player.KillCooldown = KILL_COOLDOWN
+	g.emit(Event{Type: EVENT_PLAYER_KILLED, Player: playerIdx, Target: target})
+	g.checkOver()
+	return nil
+}
+
+func (g *Game) die(playerIdx int) {
+	g.Players[playerIdx].Dead = true
+	g.Players[playerIdx].Direction = [2]int8{}
+	g.Players[playerIdx].ActiveTask = -1
+	g.Players[playerIdx].Corpse = [2]uint32{
+		g.Players[playerIdx].X,
+		g.Players[playerIdx].Y,
+	}
+}
+
+// Removes a player who disconnected from the game.
+func (g *Game) Leave(playerIdx int) error {
+	player, err := g.player(playerIdx)
+	if err != nil {
+		return err
+	}
+	if !player.Dead {
+		g.die(playerIdx)
+	}
+	player.Disconnected = true
+	g.emit(Event{Type: EVENT_PLAYER_LEFT, Player: playerIdx})
+
+	if g.Phase == PHASE_MEETING {
+		g.Meeting.Votes[playerIdx] = NO_VOTE
+		g.checkVotes()
+	}
+	g.checkOver()
+	return nil
+}
+
+// Reports the nearest corpse within REPORT_RADIUS of
+// a player, which starts a meeting.
+func (g *Game) Report(playerIdx int) error {
+	player, err := g.playing(playerIdx)
+	if err != nil {
+		return err
+	}
+
+	corpse := -1
+	nearest := REPORT_RADIUS*REPORT_RADIUS + 1
+	for i, p := range g.Players {
+		if !p.Body {
+			continue
+		}
+		if d := distance2(p.Corpse[0], p.Corpse[1], player.X, player.Y); d < nearest {
+			corpse, nearest = i, d
+		}
 	}
+	if corpse < 0 {
+		return ErrNothingNear
+	}
+
+	g.Phase = PHASE_MEETING
+	g.Meeting = &Meeting{
+		Reporter: playerIdx,
+		Corpse:   corpse,
+		Votes:    make([]int, len(g.Players)),
+		End:      g.Tick + MEETING_TICKS,
+	}
+	for i := range g.Players {
+		p := &g.Players[i]
+		g.Meeting.Votes[i] = NO_VOTE
+		p.Body = false
+		p.Direction = [2]int8{}
+		p.ActiveTask = -1
+	}
+	g.emit(Event{Type: EVENT_MEETING_CALLED, Player: playerIdx, Target: corpse})
+	return nil
+}
+
+// Votes to eject a player during a meeting,
+// or to skip if target is SKIP_VOTE.
+func (g *Game) Vote(playerIdx int, target int) error {
+	if _, err := g.living(playerIdx); err != nil {
+		return err
+	}
+	if g.Phase != PHASE_MEETING {
+		return ErrPhase
+	}
+	if g.Meeting.Votes[playerIdx] != NO_VOTE {
+		return ErrAlreadyVoted
+	}
+	if target != SKIP_VOTE {
+		if _, err := g.living(target); err != nil {
+			return err
+		}
+	}
+
+	g.Meeting.Votes[playerIdx] = target
+	g.emit(Event{Type: EVENT_VOTE_CAST, Player: playerIdx, Target: target})
+	g.checkVotes()
+	return nil
 }
 
-func (g *Game) Update(step uint) {
+// Ends the meeting once every living player has voted.
+func (g *Game) checkVotes() {
+	for i, p := range g.Players {
+		if !p.Dead && g.Meeting.Votes[i] == NO_VOTE {
+			return
+		}
+	}
+	g.endMeeting()
+}
+
+// Ejects the player with the most votes, unless
+// skipping has as many votes or there is a tie.
+func (g *Game) endMeeting() {
+	counts := make([]int, len(g.Players))
+	skips := 0
+	for _, vote := range g.Meeting.Votes {
+		if vote == SKIP_VOTE {
+			skips++
+		} else if vote >= 0 {
+			counts[vote]++
+		}
+	}
+
+	ejected, most := -1, skips
+	for i, count := range counts {
+		if count > most {
+			ejected, most = i, count
+		} else if count == most {
+			ejected = -1
+		}
+	}
+
+	g.Phase = PHASE_PLAYING
+	g.Meeting = nil
+	for i := range g.Players {
+		if g.Players[i].Imposter {
+			g.Players[i].KillCooldown = KILL_COOLDOWN
+		}
+	}
+	if ejected >= 0 {
+		g.die(ejected)
+	}
+	g.emit(Event{Type: EVENT_MEETING_ENDED, Player: -1, Target: ejected})
+	g.checkOver()
+}
+
+// Moves an impostor from a vent within
+// USE_RADIUS of them to the next vent.
+func (g *Game) UseVent(playerIdx int) error {
+	player, err := g.playing(playerIdx)
+	if err != nil {
+		return err
+	}
+	if !player.Imposter {
+		return ErrNotImpostor
+	}
+
+	var vents []Station
+	from := -1
+	for _, station := range g.Map.Stations {
+		if station.Name != VENT {
+			continue
+		}
+		if from < 0 && distance2(station.X, station.Y, player.X, player.Y) <= USE_RADIUS*USE_RADIUS {
+			from = len(vents)
+		}
+		vents = append(vents, station)
+	}
+	if from < 0 || len(vents) < 2 {
+		return ErrNothingNear
+	}
+
+	// Vents are obstacles, but the cell below them is free.
+	to := vents[(from+1)%len(vents)]
+	player.X, player.Y = to.X, to.Y+1
+	player.Direction = [2]int8{}
+	g.emit(Event{Type: EVENT_PLAYER_VENTED, Player: playerIdx})
+	return nil
+}
+
+// Starts a crewmate's task at a station within USE_RADIUS of them.
+// The task is done after TASK_TICKS, unless the crewmate moves.
+// Dead crewmates can still do their tasks.
+func (g *Game) StartTask(playerIdx int) error {
+	player, err := g.player(playerIdx)
+	if err != nil {
+		return err
+	}
+	if g.Phase != PHASE_PLAYING {
+		return ErrPhase
+	}
+	if player.Imposter {
+		return ErrImpostor
+	}
+
+	for i, task := range player.Tasks {
+		station := g.Map.Stations[task.Station]
+		if !task.Done && distance2(station.X, station.Y, player.X, player.Y) <= USE_RADIUS*USE_RADIUS {
+			player.ActiveTask = i
+			player.TaskProgress = 0
+			player.Direction = [2]int8{}
+			return nil
+		}
+	}
+	return ErrNothingNear
+}
+
+// Ends the game if either side has won.
+func (g *Game) checkOver() {
+	if g.Phase == PHASE_OVER {
+		return
+	}
+
+	impostors, crewmates := 0, 0
+	tasksLeft := false
+	for _, p := range g.Players {
+		if p.Imposter && !p.Dead {
+			impostors++
+		} else if !p.Imposter && !p.Dead {
+			crewmates++
+		}
+		if !p.Imposter && !p.Disconnected && p.TasksDone() < len(p.Tasks) {
+			tasksLeft = true
+		}
+	}
+
+	if impostors == 0 {
+		g.over(false)
+	} else if impostors >= crewmates {
+		g.over(true)
+	} else if !tasksLeft {
+		g.over(false)
+	}
+}
+
+func (g *Game) over(impostorsWin bool) {
+	g.Phase = PHASE_OVER
+	g.Meeting = nil
+	for i := range g.Players {
+		g.Players[i].Direction = [2]int8{}
+		g.Players[i].ActiveTask = -1
+	}
+	g.emit(Event{Type: EVENT_GAME_OVER, Player: -1, Target: -1, ImpostorsWin: impostorsWin})
+}
+
+func (g *Game) update() {
+	if g.Phase == PHASE_MEETING && g.Tick >= g.Meeting.End {
+		g.endMeeting()
+	}
+	if g.Phase != PHASE_PLAYING {
+		return
+	}
+
 	for i, player := range g.Players {
 		x := g.Players[i].UpdatePositionX(g.Map.Width)
 		y := g.Players[i].Y
-		if step%2 == 0 {
+		if g.Tick%2 == 0 {
 			y = g.Players[i].UpdatePositionY(g.Map.Height())
 		}
 		c := g.Map.Data[y*g.Map.Width+x]
@@ -93,12 +663,21 @@ func (g *Game) Update(step uint) {
 			g.Players[i].Y = y
 		}
 	}
-}
 
-func (g *Game) Kill(playerIdx int) {
-	g.Players[playerIdx].Dead = true
-	g.Players[playerIdx].Corpse = [2]uint32{
-		g.Players[playerIdx].X,
-		g.Players[playerIdx].Y,
+	for i := range g.Players {
+		p := &g.Players[i]
+		if p.KillCooldown > 0 {
+			p.KillCooldown--
+		}
+		if p.ActiveTask < 0 {
+			continue
+		}
+		p.TaskProgress++
+		if p.TaskProgress >= TASK_TICKS {
+			p.Tasks[p.ActiveTask].Done = true
+			g.emit(Event{Type: EVENT_TASK_COMPLETED, Player: i, Target: p.ActiveTask})
+			p.ActiveTask = -1
+			g.checkOver()
+		}
 	}
 }
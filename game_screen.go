@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/allen-b1/sus-tux/nui"
+)
+
+// Holds the latest snapshot of a game. The game loop stores
+// a new one every tick, and the widgets of the players' game
+// screens draw whichever one is the latest, or nothing once
+// the game is over.
+type GameView struct {
+	value atomic.Value /* *GameSnapshot */
+}
+
+func (v *GameView) Load() *GameSnapshot {
+	snap, _ := v.value.Load().(*GameSnapshot)
+	return snap
+}
+
+func (v *GameView) Store(snap *GameSnapshot) {
+	v.value.Store(snap)
+}
+
+// Shows a player's name, role and progress.
+type gameHeader struct {
+	X, Y      uint16
+	Map       *Map
+	View      *GameView
+	PlayerIdx int
+	Name      string
+}
+
+func (h *gameHeader) Draw(buf *nui.Buffer) {
+	snap := h.View.Load()
+	if snap == nil {
+		// The game is over
+		return
+	}
+	player := snap.Players[h.PlayerIdx]
+
+	var status, help string
+	if player.Imposter {
+		status = "Kill: ready"
+		if player.KillCooldown > 0 {
+			status = fmt.Sprintf("Kill in %ds", (time.Duration(player.KillCooldown)*TICK_DURATION+time.Second-1)/time.Second)
+		}
+		help = "wasd move  q stop  k kill  r report  v vent"
+	} else {
+		status = fmt.Sprintf("Tasks: %d/%d", player.TasksDone(), len(player.Tasks))
+		if player.ActiveTask >= 0 {
+			station := h.Map.Stations[player.Tasks[player.ActiveTask].Station]
+			status += fmt.Sprintf(" (doing %c: %d%%)", station.Name, 100*player.TaskProgress/TASK_TICKS)
+		}
+		help = "wasd move  q stop  e do task  r report"
+	}
+	if player.Dead {
+		status += "  You are dead"
+	}
+
+	x, y := int(h.X), int(h.Y)
+	buf.WriteString(x, y, h.Name, nui.Format{Fg: playerColor(h.PlayerIdx), Bg: nui.Black})
+	parts := []struct {
+		text   string
+		format nui.Format
+	}{
+		{"Role:", nui.Format{Fg: nui.White, Bg: nui.Black}},
+		{ternaryString(player.Imposter, "Impostor", "Crewmate"), nui.Format{Fg: ternaryColor(player.Imposter, nui.LightRed, nui.LightBlue), Bg: nui.Black, Bold: true}},
+		{"  " + status, nui.Format{Fg: nui.White, Bg: nui.Black}},
+		{"  " + help, nui.Format{Fg: nui.LightBlack, Bg: nui.Black}},
+	}
+	for _, part := range parts {
+		buf.WriteString(x, y+1, part.text, part.format)
+		x += len(part.text) + 1
+	}
+}
+
+func (h *gameHeader) Size() (uint16, uint16) {
+	return MAP_WIDTH - 4, 2
+}
+
+func (h *gameHeader) Place(area nui.Rect) {
+	h.X, h.Y = area.X, area.Y
+}
+
+// Shows the map around a player from the latest snapshot.
+type gameMap struct {
+	MapWidget
+	View      *GameView
+	PlayerIdx int
+}
+
+func (m *gameMap) Draw(buf *nui.Buffer) {
+	snap := m.View.Load()
+	if snap == nil {
+		return
+	}
+
+	// The widget may be drawn for several clients at once,
+	// so the snapshot is set on a copy.
+	widget := m.MapWidget
+	widget.Players = snap.Players
+	widget.Player = &snap.Players[m.PlayerIdx]
+	widget.Draw(buf)
+}
+
+// Returns the screen that a player sees during the whole game.
+// Memory safety: The state must be locked.
+func makeGameScreen(state *State, playerIdx int) *nui.Screen {
+	mapWidget := &gameMap{
+		MapWidget: MapWidget{
+			PlayerColor: playerColor(playerIdx),
+			Map:         state.game.Map,
+			CommandHandler: func(cmd Command) {
+				cmd.Player = playerIdx
+				state.sendCommand(cmd)
+			},
+		},
+		View:      &state.view,
+		PlayerIdx: playerIdx,
+	}
+	header := &gameHeader{Map: state.game.Map, View: &state.view, PlayerIdx: playerIdx, Name: state.playerName(playerIdx)}
+
+	return &nui.Screen{
+		Focus: 0,
+		Widgets: []nui.Widget{
+			&nui.VBox{Children: []nui.Widget{
+				&nui.Padding{Top: 1, Bottom: 1, Left: 2, Child: header},
+				mapWidget,
+			}},
+		},
+	}
+}
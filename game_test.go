@@ -0,0 +1,499 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// A small map with two task stations and two vents.
+const TEST_MAP = "+++++++\n+a   v+\n+     +\n+b   v+\n+++++++"
+
+// Returns a game on the test map in which player 0 is the impostor,
+// and the events that happen in it from then on.
+func newTestGame(t *testing.T, nplayers int) (*Game, *[]Event) {
+	t.Helper()
+	g := NewGame(nplayers, NewMap(TEST_MAP), 1)
+	for i, p := range g.Players {
+		if p.Imposter {
+			// Everyone starts in the same place
+			g.Players[0], g.Players[i] = g.Players[i], g.Players[0]
+		}
+	}
+	events := new([]Event)
+	g.Subscribe(func(ev Event) {
+		*events = append(*events, ev)
+	})
+	return g, events
+}
+
+// Games that one side has won from the start end on the first tick.
+func TestGameOverAtStart(t *testing.T) {
+	tests := []struct {
+		nplayers     int
+		over         bool
+		impostorsWin bool
+	}{
+		{1, true, true},
+		{2, true, true},
+		{3, false, false},
+	}
+	for _, test := range tests {
+		g, events := newTestGame(t, test.nplayers)
+		g.Step(nil)
+		if over := g.Phase == PHASE_OVER; over != test.over {
+			t.Errorf("%d players: game over is %v, want %v", test.nplayers, over, test.over)
+			continue
+		}
+		if !test.over {
+			continue
+		}
+		want := Event{Type: EVENT_GAME_OVER, Player: -1, Target: -1, ImpostorsWin: test.impostorsWin}
+		if len(*events) != 1 || (*events)[0] != want {
+			t.Errorf("%d players: events are %+v, want %+v", test.nplayers, *events, want)
+		}
+	}
+}
+
+// Stations of the test map, which are ordered by column
+const (
+	TEST_TASK_A = iota
+	TEST_TASK_B
+	TEST_VENT_1
+	TEST_VENT_2
+)
+
+// Moves a player to the free cell below a station of the test map.
+func moveToStation(g *Game, playerIdx int, station int) {
+	s := g.Map.Stations[station]
+	g.Players[playerIdx].X, g.Players[playerIdx].Y = s.X, s.Y+1
+}
+
+// Player 0 kills player 1, and player 2 reports the body.
+func startTestMeeting(t *testing.T, g *Game) {
+	t.Helper()
+	g.Players[0].KillCooldown = 0
+	if err := g.Kill(0); err != nil {
+		t.Fatal("kill:", err)
+	}
+	if err := g.Report(2); err != nil {
+		t.Fatal("report:", err)
+	}
+}
+
+func TestGameCommands(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(t *testing.T, g *Game)
+		cmd    Command
+		err    error
+		events []Event
+		check  func(t *testing.T, g *Game)
+	}{
+		{
+			name: "move",
+			cmd:  Command{Player: 1, Type: CMD_MOVE, Direction: [2]int8{1, -1}},
+			check: func(t *testing.T, g *Game) {
+				if g.Players[1].Direction != [2]int8{1, -1} {
+					t.Errorf("direction is %v", g.Players[1].Direction)
+				}
+			},
+		},
+		{
+			name: "move too fast",
+			cmd:  Command{Player: 1, Type: CMD_MOVE, Direction: [2]int8{2, 0}},
+			err:  ErrInvalidMove,
+		},
+		{
+			name: "move as no one",
+			cmd:  Command{Player: 4, Type: CMD_MOVE, Direction: [2]int8{1, 0}},
+			err:  ErrInvalidPlayer,
+		},
+		{
+			name:  "move during a meeting",
+			setup: startTestMeeting,
+			cmd:   Command{Player: 2, Type: CMD_MOVE, Direction: [2]int8{1, 0}},
+			err:   ErrPhase,
+		},
+		{
+			name:   "kill",
+			setup:  func(t *testing.T, g *Game) { g.Players[0].KillCooldown = 0 },
+			cmd:    Command{Player: 0, Type: CMD_KILL},
+			events: []Event{{Type: EVENT_PLAYER_KILLED, Player: 0, Target: 1}},
+			check: func(t *testing.T, g *Game) {
+				p := g.Players[1]
+				if !p.Dead || !p.Body || p.Corpse != [2]uint32{p.X, p.Y} {
+					t.Errorf("victim is %+v", p)
+				}
+				if g.Players[0].KillCooldown != KILL_COOLDOWN {
+					t.Errorf("cooldown is %d", g.Players[0].KillCooldown)
+				}
+			},
+		},
+		{
+			name: "kill the nearest",
+			setup: func(t *testing.T, g *Game) {
+				g.Players[0].KillCooldown = 0
+				g.Players[1].X++
+				g.Players[3].X--
+				g.Players[3].Y++
+			},
+			cmd:    Command{Player: 0, Type: CMD_KILL},
+			events: []Event{{Type: EVENT_PLAYER_KILLED, Player: 0, Target: 2}},
+		},
+		{
+			name: "kill on cooldown",
+			cmd:  Command{Player: 0, Type: CMD_KILL},
+			err:  ErrCooldown,
+		},
+		{
+			name:  "kill as a crewmate",
+			setup: func(t *testing.T, g *Game) { g.Players[1].KillCooldown = 0 },
+			cmd:   Command{Player: 1, Type: CMD_KILL},
+			err:   ErrNotImpostor,
+		},
+		{
+			name: "kill with no one near",
+			setup: func(t *testing.T, g *Game) {
+				g.Players[0].KillCooldown = 0
+				moveToStation(g, 0, TEST_VENT_1)
+			},
+			cmd: Command{Player: 0, Type: CMD_KILL},
+			err: ErrNothingNear,
+		},
+		{
+			name: "kill during a meeting",
+			setup: func(t *testing.T, g *Game) {
+				startTestMeeting(t, g)
+				g.Players[0].KillCooldown = 0
+			},
+			cmd: Command{Player: 0, Type: CMD_KILL},
+			err: ErrPhase,
+		},
+		{
+			name: "report",
+			setup: func(t *testing.T, g *Game) {
+				g.Players[0].KillCooldown = 0
+				g.Kill(0)
+			},
+			cmd:    Command{Player: 2, Type: CMD_REPORT},
+			events: []Event{{Type: EVENT_MEETING_CALLED, Player: 2, Target: 1}},
+			check: func(t *testing.T, g *Game) {
+				if g.Phase != PHASE_MEETING || g.Meeting.Reporter != 2 || g.Meeting.Corpse != 1 {
+					t.Errorf("phase is %d, meeting is %+v", g.Phase, g.Meeting)
+				}
+				if g.Players[1].Body {
+					t.Error("body is still on the map")
+				}
+			},
+		},
+		{
+			name: "report with no body",
+			cmd:  Command{Player: 2, Type: CMD_REPORT},
+			err:  ErrNothingNear,
+		},
+		{
+			name: "report while dead",
+			setup: func(t *testing.T, g *Game) {
+				g.Players[0].KillCooldown = 0
+				g.Kill(0)
+			},
+			cmd: Command{Player: 1, Type: CMD_REPORT},
+			err: ErrDead,
+		},
+		{
+			name:   "vote",
+			setup:  startTestMeeting,
+			cmd:    Command{Player: 2, Type: CMD_VOTE, Target: 0},
+			events: []Event{{Type: EVENT_VOTE_CAST, Player: 2, Target: 0}},
+		},
+		{
+			name: "vote outside a meeting",
+			cmd:  Command{Player: 2, Type: CMD_VOTE, Target: 0},
+			err:  ErrPhase,
+		},
+		{
+			name: "vote twice",
+			setup: func(t *testing.T, g *Game) {
+				startTestMeeting(t, g)
+				g.Vote(2, SKIP_VOTE)
+			},
+			cmd: Command{Player: 2, Type: CMD_VOTE, Target: 0},
+			err: ErrAlreadyVoted,
+		},
+		{
+			name:  "vote while dead",
+			setup: startTestMeeting,
+			cmd:   Command{Player: 1, Type: CMD_VOTE, Target: 0},
+			err:   ErrDead,
+		},
+		{
+			name:  "vote for the dead",
+			setup: startTestMeeting,
+			cmd:   Command{Player: 2, Type: CMD_VOTE, Target: 1},
+			err:   ErrDead,
+		},
+		{
+			name:   "vent",
+			setup:  func(t *testing.T, g *Game) { moveToStation(g, 0, TEST_VENT_1) },
+			cmd:    Command{Player: 0, Type: CMD_VENT},
+			events: []Event{{Type: EVENT_PLAYER_VENTED, Player: 0}},
+			check: func(t *testing.T, g *Game) {
+				vent := g.Map.Stations[TEST_VENT_2]
+				if p := g.Players[0]; p.X != vent.X || p.Y != vent.Y+1 {
+					t.Errorf("impostor is at %d, %d", p.X, p.Y)
+				}
+			},
+		},
+		{
+			name:  "vent as a crewmate",
+			setup: func(t *testing.T, g *Game) { moveToStation(g, 1, TEST_VENT_1) },
+			cmd:   Command{Player: 1, Type: CMD_VENT},
+			err:   ErrNotImpostor,
+		},
+		{
+			name: "vent away from vents",
+			cmd:  Command{Player: 0, Type: CMD_VENT},
+			err:  ErrNothingNear,
+		},
+		{
+			name: "start a task",
+			setup: func(t *testing.T, g *Game) {
+				moveToStation(g, 1, g.Players[1].Tasks[1].Station)
+			},
+			cmd: Command{Player: 1, Type: CMD_TASK},
+			check: func(t *testing.T, g *Game) {
+				if g.Players[1].ActiveTask != 1 {
+					t.Errorf("active task is %d", g.Players[1].ActiveTask)
+				}
+			},
+		},
+		{
+			name:  "start a task as an impostor",
+			setup: func(t *testing.T, g *Game) { moveToStation(g, 0, TEST_TASK_A) },
+			cmd:   Command{Player: 0, Type: CMD_TASK},
+			err:   ErrImpostor,
+		},
+		{
+			name: "start a task away from stations",
+			cmd:  Command{Player: 1, Type: CMD_TASK},
+			err:  ErrNothingNear,
+		},
+		{
+			name: "start a task that is done",
+			setup: func(t *testing.T, g *Game) {
+				g.Players[1].Tasks[0].Done = true
+				moveToStation(g, 1, g.Players[1].Tasks[0].Station)
+			},
+			cmd: Command{Player: 1, Type: CMD_TASK},
+			err: ErrNothingNear,
+		},
+		{
+			name:   "leave",
+			cmd:    Command{Player: 1, Type: CMD_LEAVE},
+			events: []Event{{Type: EVENT_PLAYER_LEFT, Player: 1}},
+			check: func(t *testing.T, g *Game) {
+				if p := g.Players[1]; !p.Dead || !p.Disconnected || p.Body {
+					t.Errorf("player is %+v", p)
+				}
+			},
+		},
+		{
+			name: "leave twice",
+			setup: func(t *testing.T, g *Game) {
+				g.Leave(1)
+			},
+			cmd: Command{Player: 1, Type: CMD_LEAVE},
+			err: ErrInvalidPlayer,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, events := newTestGame(t, 4)
+			if test.setup != nil {
+				test.setup(t, g)
+			}
+			*events = nil
+
+			if err := g.Do(test.cmd); err != test.err {
+				t.Fatalf("error is %v, want %v", err, test.err)
+			}
+			if len(*events) != len(test.events) {
+				t.Fatalf("events are %+v, want %+v", *events, test.events)
+			}
+			for i, ev := range *events {
+				if ev != test.events[i] {
+					t.Errorf("event %d is %+v, want %+v", i, ev, test.events[i])
+				}
+			}
+			if test.check != nil {
+				test.check(t, g)
+			}
+		})
+	}
+}
+
+func TestGameMeetings(t *testing.T) {
+	tests := []struct {
+		name string
+		// Votes of players 0, 2 and 3, in this order;
+		// NO_VOTE leaves a player out.
+		votes   [3]int
+		ejected int
+		// Set if ejecting the player ends the game
+		over bool
+	}{
+		{"majority", [3]int{3, 3, SKIP_VOTE}, 3, true},
+		{"impostor", [3]int{SKIP_VOTE, 0, 0}, 0, true},
+		{"tie", [3]int{2, 3, SKIP_VOTE}, -1, false},
+		{"tie with skips", [3]int{SKIP_VOTE, 0, NO_VOTE}, -1, false},
+		{"skip", [3]int{SKIP_VOTE, SKIP_VOTE, 0}, -1, false},
+		{"time out", [3]int{3, NO_VOTE, NO_VOTE}, 3, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, events := newTestGame(t, 4)
+			startTestMeeting(t, g)
+
+			for i, playerIdx := range []int{0, 2, 3} {
+				if test.votes[i] == NO_VOTE {
+					continue
+				}
+				if err := g.Vote(playerIdx, test.votes[i]); err != nil {
+					t.Fatalf("player %d: %v", playerIdx, err)
+				}
+			}
+			if g.Phase == PHASE_PLAYING && g.Players[0].KillCooldown != KILL_COOLDOWN {
+				t.Errorf("kill cooldown is %d after the meeting", g.Players[0].KillCooldown)
+			}
+			for g.Phase == PHASE_MEETING {
+				if g.Tick > MEETING_TICKS {
+					t.Fatal("meeting did not end")
+				}
+				g.Step(nil)
+			}
+
+			var ended *Event
+			for i, ev := range *events {
+				if ev.Type == EVENT_MEETING_ENDED {
+					ended = &(*events)[i]
+				}
+			}
+			if ended == nil || ended.Target != test.ejected {
+				t.Fatalf("meeting ended with %+v, want %d ejected", ended, test.ejected)
+			}
+			if test.ejected >= 0 && !g.Players[test.ejected].Dead {
+				t.Error("ejected player is alive")
+			}
+			if over := g.Phase == PHASE_OVER; over != test.over {
+				t.Errorf("game over is %v, want %v", over, test.over)
+			}
+		})
+	}
+}
+
+func TestGameOver(t *testing.T) {
+	tests := []struct {
+		name         string
+		play         func(t *testing.T, g *Game)
+		impostorsWin bool
+	}{
+		{
+			name: "impostor leaves",
+			play: func(t *testing.T, g *Game) {
+				g.Leave(0)
+			},
+			impostorsWin: false,
+		},
+		{
+			name: "as many impostors as crewmates",
+			play: func(t *testing.T, g *Game) {
+				g.Players[0].KillCooldown = 0
+				g.Kill(0)
+				g.Leave(2)
+			},
+			impostorsWin: true,
+		},
+		{
+			name: "tasks done",
+			play: func(t *testing.T, g *Game) {
+				for i := 1; i < len(g.Players); i++ {
+					for j := range g.Players[i].Tasks {
+						g.Players[i].Tasks[j].Done = i != 1 || j != 0
+					}
+				}
+				// Dead crewmates still do their tasks
+				g.Players[0].KillCooldown = 0
+				g.Kill(0)
+				moveToStation(g, 1, g.Players[1].Tasks[0].Station)
+				if err := g.StartTask(1); err != nil {
+					t.Fatal(err)
+				}
+				for i := 0; i < TASK_TICKS; i++ {
+					g.Step(nil)
+				}
+			},
+			impostorsWin: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, events := newTestGame(t, 4)
+			g.Step(nil)
+			test.play(t, g)
+
+			if g.Phase != PHASE_OVER {
+				t.Fatal("game is not over")
+			}
+			last := (*events)[len(*events)-1]
+			want := Event{Type: EVENT_GAME_OVER, Tick: last.Tick, Player: -1, Target: -1, ImpostorsWin: test.impostorsWin}
+			if last != want {
+				t.Errorf("last event is %+v, want %+v", last, want)
+			}
+			if err := g.Move(1, [2]int8{1, 0}); err != ErrPhase {
+				t.Errorf("moving after the game is %v", err)
+			}
+		})
+	}
+}
+
+// Plays a game with bots, then plays their commands again on a new
+// game with the same seed, which must be the same on every tick.
+func TestGameDeterministic(t *testing.T) {
+	for seed := int64(1); seed <= 3; seed++ {
+		played := NewGame(MAX_PLAYERS, researchFacility, seed)
+		nav := newNavigator(played.Map)
+		var bots []*Bot
+		for i := range played.Players {
+			bot := NewBot(played, i, nav)
+			played.Subscribe(bot.HandleEvent)
+			bots = append(bots, bot)
+		}
+		var events, replayedEvents []Event
+		played.Subscribe(func(ev Event) { events = append(events, ev) })
+
+		replayed := NewGame(MAX_PLAYERS, researchFacility, seed)
+		replayed.Subscribe(func(ev Event) { replayedEvents = append(replayedEvents, ev) })
+
+		for played.Phase != PHASE_OVER {
+			if played.Tick > 20000 {
+				t.Fatalf("seed %d: game did not end", seed)
+			}
+			var commands []Command
+			for _, bot := range bots {
+				commands = append(commands, bot.Think(played)...)
+			}
+			played.Step(commands)
+			replayed.Step(commands)
+
+			if !reflect.DeepEqual(played.Snapshot(), replayed.Snapshot()) || !reflect.DeepEqual(played.Meeting, replayed.Meeting) {
+				t.Fatalf("seed %d: games differ after tick %d:\n%+v\n%+v", seed, played.Tick, played.Snapshot(), replayed.Snapshot())
+			}
+		}
+		if !reflect.DeepEqual(events, replayedEvents) {
+			t.Errorf("seed %d: events differ:\n%+v\n%+v", seed, events, replayedEvents)
+		}
+	}
+}
@@ -1,10 +1,14 @@
 module github.com/allen-b1/sus-tux
 
-go 1.17
+// The SSH server accepts anyone, so golang.org/x/crypto is kept at
+// its current release. Its current releases, and those of x/net,
+// require Go 1.26, which is also the oldest Go release that is
+// still supported with security fixes.
+go 1.26.0
 
 require (
-	github.com/mattn/go-colorable v0.1.12 // indirect
-	github.com/mattn/go-isatty v0.0.14 // indirect
-	github.com/rs/zerolog v1.28.0 // indirect
-	golang.org/x/sys v0.0.0-20210927094055-39ccf1dd6fa6 // indirect
+	golang.org/x/crypto v0.57.0
+	golang.org/x/net v0.58.0
 )
+
+require golang.org/x/sys v0.48.0 // indirect
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reads that arrive at least this long after the previous
+// one are counted as the start of a new frame.
+const LOADTEST_FRAME_GAP = 10 * time.Millisecond
+
+// Time that the clients wait for the lobby, and for the
+// game to start, before the measurements begin.
+const LOADTEST_SETTLE = time.Second
+
+// A simulated telnet client of a load test.
+type loadClient struct {
+	conn    net.Conn
+	dialed  time.Time
+	connect time.Duration // until the first byte arrived
+
+	lock      sync.Mutex
+	bytes     int64
+	lastRead  time.Time
+	lastFrame time.Time
+	// Time at which a key was sent that no frame followed yet.
+	keySent time.Time
+	// Time between frames, and from keys to the next frame.
+	frames    []time.Duration
+	latencies []time.Duration
+	closed    bool
+}
+
+func (c *loadClient) read() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := c.conn.Read(buf)
+		now := time.Now()
+
+		c.lock.Lock()
+		if n > 0 {
+			if c.connect == 0 {
+				c.connect = now.Sub(c.dialed)
+			}
+			c.bytes += int64(n)
+			if now.Sub(c.lastRead) >= LOADTEST_FRAME_GAP {
+				if !c.lastFrame.IsZero() {
+					c.frames = append(c.frames, now.Sub(c.lastFrame))
+				}
+				c.lastFrame = now
+				if !c.keySent.IsZero() {
+					c.latencies = append(c.latencies, now.Sub(c.keySent))
+					c.keySent = time.Time{}
+				}
+			}
+			c.lastRead = now
+		}
+		if err != nil {
+			c.closed = true
+			c.lock.Unlock()
+			return
+		}
+		c.lock.Unlock()
+	}
+}
+
+func (c *loadClient) send(keys string) error {
+	c.lock.Lock()
+	if c.keySent.IsZero() {
+		c.keySent = time.Now()
+	}
+	c.lock.Unlock()
+	_, err := c.conn.Write([]byte(keys))
+	return err
+}
+
+func (c *loadClient) isClosed() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.closed
+}
+
+// Forgets what was measured before the game started.
+func (c *loadClient) reset() {
+	c.lock.Lock()
+	c.bytes = 0
+	c.lastFrame = time.Time{}
+	c.keySent = time.Time{}
+	c.frames = nil
+	c.latencies = nil
+	c.lock.Unlock()
+}
+
+// Sends random movement keys until stop is closed.
+func (c *loadClient) play(interval time.Duration, seed int64, stop chan struct{}) {
+	r := rand.New(rand.NewSource(seed))
+	// Spreads the clients' keys over the interval
+	timer := time.NewTimer(time.Duration(r.Int63n(int64(interval))))
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-stop:
+			return
+		}
+		if err := c.send(string("wasdq"[r.Intn(5)])); err != nil {
+			return
+		}
+		timer.Reset(interval/2 + time.Duration(r.Int63n(int64(interval))))
+	}
+}
+
+// Returns the given percentile of sorted durations.
+func percentile(durations []time.Duration, p int) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	return durations[(len(durations)-1)*p/100]
+}
+
+func formatDurations(durations []time.Duration) string {
+	if len(durations) == 0 {
+		return "no samples"
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	round := func(d time.Duration) time.Duration { return d.Round(100 * time.Microsecond) }
+	return fmt.Sprintf("p50 %v  p95 %v  p99 %v  max %v  (%d samples)",
+		round(percentile(durations, 50)), round(percentile(durations, 95)),
+		round(percentile(durations, 99)), round(durations[len(durations)-1]), len(durations))
+}
+
+// Connects simulated clients to a server, which should have an empty
+// lobby, starts a game with the first one as the host, and has all of
+// them walk around at random while measuring how the server keeps up.
+func loadtest(args []string) {
+	flags := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	addr := flags.String("addr", "localhost:6567", "address of the server's plain TCP listener")
+	nclients := flags.Int("clients", MAX_PLAYERS, fmt.Sprintf("number of clients to connect; no more than %d can join a game", MAX_PLAYERS))
+	duration := flags.Duration("duration", 30*time.Second, "how long to play once the game started")
+	interval := flags.Duration("interval", 250*time.Millisecond, "average time between the keys that each client sends")
+	flags.Parse(args)
+
+	if *nclients < 1 || *interval <= 0 {
+		log.Fatalln("-clients and -interval must be positive")
+	}
+
+	var clients []*loadClient
+	for i := 0; i < *nclients; i++ {
+		c := &loadClient{dialed: time.Now()}
+		conn, err := net.Dial("tcp", *addr)
+		if err != nil {
+			log.Fatalln("error connecting client:", err)
+		}
+		c.conn = conn
+		clients = append(clients, c)
+		go c.read()
+		// Clients join in order, so the first one hosts.
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer func() {
+		for _, c := range clients {
+			c.conn.Close()
+		}
+	}()
+	time.Sleep(LOADTEST_SETTLE)
+
+	var joined []*loadClient
+	for _, c := range clients {
+		if !c.isClosed() {
+			joined = append(joined, c)
+		}
+	}
+	if len(joined) == 0 || joined[0] != clients[0] {
+		log.Fatalln("the first client could not join; is the lobby empty?")
+	}
+
+	// The host tabs past the other players to the start button.
+	log.Printf("%d clients joined, starting the game\n", len(joined))
+	if err := joined[0].send(strings.Repeat("\t", len(joined)) + "\n"); err != nil {
+		log.Fatalln("error starting the game:", err)
+	}
+	time.Sleep(LOADTEST_SETTLE)
+	for _, c := range joined {
+		c.reset()
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, c := range joined {
+		wg.Add(1)
+		go func(i int, c *loadClient) {
+			defer wg.Done()
+			c.play(*interval, int64(i), stop)
+		}(i, c)
+	}
+	start := time.Now()
+	time.Sleep(*duration)
+	close(stop)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	var connects, frames, latencies []time.Duration
+	var total, least, most float64
+	dropped := 0
+	for i, c := range joined {
+		c.lock.Lock()
+		rate := float64(c.bytes) / elapsed.Seconds()
+		total += rate
+		if i == 0 || rate < least {
+			least = rate
+		}
+		if i == 0 || rate > most {
+			most = rate
+		}
+		if c.closed {
+			dropped++
+		}
+		connects = append(connects, c.connect)
+		frames = append(frames, c.frames...)
+		latencies = append(latencies, c.latencies...)
+		c.lock.Unlock()
+	}
+
+	fmt.Printf("clients:        %d joined, %d rejected, %d dropped during the game\n", len(joined), len(clients)-len(joined), dropped)
+	fmt.Printf("duration:       %v\n", elapsed.Round(time.Millisecond))
+	fmt.Printf("received:       %.1f KiB/s per client (min %.1f, max %.1f), %.1f KiB/s in total\n",
+		total/float64(len(joined))/1024, least/1024, most/1024, total/1024)
+	fmt.Printf("connect time:   %s\n", formatDurations(connects))
+	fmt.Printf("frame interval: %s  (tick is %v)\n", formatDurations(frames), TICK_DURATION)
+	fmt.Printf("key to frame:   %s\n", formatDurations(latencies))
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	_ "embed"
+	"flag"
 	"fmt"
 	"log"
-	"math/rand"
 	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/allen-b1/sus-tux/nui"
@@ -18,10 +24,13 @@ var researchFacility *Map
 
 func init() {
 	researchFacility = NewMap(researchFacilityData)
+	maps["research_facility"] = researchFacility
 }
 
 type Player struct {
 	name string
+	// Bots play without a client.
+	bot bool
 }
 
 type State struct {
@@ -30,191 +39,572 @@ type State struct {
 	players []Player
 
 	game *Game
+	// Bots playing the game
+	bots []*Bot
+	// Latest snapshot of the game, which
+	// the game screens are drawn from.
+	view GameView
+	// Screens that players vote on during a meeting,
+	// by client ID.
+	voteScreens map[int]*nui.Screen
+	// Lists of the players on the lobby screens, by client
+	// ID, which are updated when a player changes their name.
+	lobbyPlayers map[int]*nui.VBox
+	// Seed of the next game, or 0 for a random seed.
+	seed int64
+
+	// Commands sent by players since the last tick,
+	// which are guarded by their own lock so that
+	// sending one never waits for a tick.
+	commands     []Command
+	commandsLock sync.Mutex
+
+	// Addresses that may not join.
+	bans sync.Map /* string => bool */
+
+	// Done when the server is shutting down,
+	// which stops the game loop.
+	ctx context.Context
+
+	// Directory that replays of games are saved to, if any,
+	// and the replay of the current game.
+	replayDir string
+	replay    *ReplayWriter
+
+	// Directory that recordings are saved to, and the index
+	// of the player whose view of the game is recorded, or -1.
+	recordDir string
+	spectate  int
+
+	// Held while a player's new name is shown on the
+	// other players' lobby screens, so that those
+	// updates happen in the same order as the changes.
+	lobbyLock sync.Mutex
 
 	// This field should be locked whenever
 	// any other fields are being read or written to.
+	//
+	// Lock order: The state may be locked while a screen is
+	// locked, such as in an event handler of a widget, but a
+	// screen must never be locked while the state is locked.
+	// No more than one screen may be locked at a time.
 	sync.RWMutex
 }
 
-func makeGameScreen(state *State, playerIdx int) *nui.Screen {
+// Returns the name of a player, or a
+// placeholder if they did not choose one.
+func (s *State) playerName(playerIdx int) string {
+	return displayName(s.players[playerIdx].name, playerIdx)
+}
+
+// Returns the client ID of a player who is connected.
+func (s *State) playerClient(playerIdx int) (int, bool) {
+	for clientID, idx := range s.clients {
+		if idx == playerIdx {
+			return clientID, true
+		}
+	}
+	return 0, false
+}
+
+// Returns the index of the player who hosts the lobby,
+// which is the first one who is not a bot, or -1.
+func (s *State) host() int {
+	host := -1
+	for _, playerIdx := range s.clients {
+		if host < 0 || playerIdx < host {
+			host = playerIdx
+		}
+	}
+	return host
+}
+
+// Queues a command for the next tick.
+func (s *State) sendCommand(cmd Command) {
+	s.commandsLock.Lock()
+	s.commands = append(s.commands, cmd)
+	s.commandsLock.Unlock()
+}
+
+// Returns the commands queued since the last call.
+func (s *State) takeCommands() []Command {
+	s.commandsLock.Lock()
+	defer s.commandsLock.Unlock()
+	commands := s.commands
+	s.commands = nil
+	return commands
+}
+
+// Asks a player who to eject during a meeting.
+func makeVoteScreen(state *State, playerIdx int) *nui.Screen {
 	g := state.game
-	player := &g.Players[playerIdx]
-	return &nui.Screen{
-		Focus: 0,
-		Widgets: []nui.Widget{
-			&MapWidget{
-				X: 0, Y: 4, PlayerColor: nui.Color(playerIdx + 1), Map: g.Map,
-				Player:  player,
-				Players: state.game.Players,
-				KillHandler: func() {
-					if player.Dead || !player.Imposter {
-						return
-					}
+	format := nui.Format{Fg: nui.LightWhite, Bg: nui.Blue}
+	message := fmt.Sprintf("%s found the body of %s.", state.playerName(g.Meeting.Reporter), state.playerName(g.Meeting.Corpse))
+	status := &nui.Label{Format: format, Text: "Who do you want to eject?"}
+	children := []nui.Widget{
+		&nui.Label{Format: format, Text: message},
+		status,
+	}
 
-					for i, target := range state.game.Players {
-						if i == playerIdx {
-							continue
-						}
-						if (target.X-player.X)*(target.X-player.X)+(target.Y-player.Y)*(target.Y-player.Y) <= KILL_RADIUS*KILL_RADIUS {
-							g.Kill(i)
-						}
-					}
-				},
-			},
-			&nui.Label{
-				X: 2, Y: 1, Format: nui.Format{Fg: nui.Color(playerIdx + 1), Bg: nui.Black},
-				Text: state.players[playerIdx].name,
-			},
-			&nui.Label{
-				X: 2, Y: 2, Format: nui.Format{Fg: nui.White, Bg: nui.Black},
-				Text: "Role:",
-			},
-			&nui.Label{
-				X: 2 + 6, Y: 2, Format: nui.Format{Fg: ternaryColor(player.Imposter, nui.LightRed, nui.LightBlue), Bg: nui.Black, Bold: true},
-				Text: ternaryString(player.Imposter, "Impostor", "Crewmate"),
-			},
-		},
+	if g.Players[playerIdx].Dead {
+		status.Text = "You are dead, so you cannot vote."
+	} else {
+		list := &nui.List{
+			Width:          24,
+			Format:         nui.Format{Fg: nui.LightWhite, Bg: nui.Black},
+			SelectedFormat: nui.Format{Fg: nui.Black, Bg: nui.LightWhite},
+		}
+		var targets []int
+		for i, p := range g.Players {
+			if p.Dead {
+				continue
+			}
+			itemFormat := nui.Format{Fg: playerColor(i), Bg: nui.Black}
+			list.Items = append(list.Items, nui.ListItem{Text: state.playerName(i), Format: &itemFormat})
+			targets = append(targets, i)
+		}
+		list.Items = append(list.Items, nui.ListItem{Text: "Skip vote"})
+		targets = append(targets, SKIP_VOTE)
+		list.Height = uint16(len(list.Items))
+
+		voted := false
+		list.HandleSelect = func(idx int) {
+			if voted {
+				return
+			}
+			voted = true
+			state.sendCommand(Command{Player: playerIdx, Type: CMD_VOTE, Target: targets[idx]})
+			status.Text = "You voted for " + list.Items[idx].Text + "."
+		}
+		children = append(children, &nui.Padding{Top: 1, Child: list})
+	}
+
+	root := &nui.Center{Child: &nui.Frame{
+		Title:  "Meeting",
+		Format: format,
+		Child:  &nui.Padding{Top: 1, Right: 2, Bottom: 1, Left: 2, Child: &nui.VBox{Children: children}},
+	}}
+	root.Focus(true)
+	return &nui.Screen{Widgets: []nui.Widget{root}}
+}
+
+// Shows what happened in the game to the players it concerns.
+// Memory safety: The state must be locked.
+func handleGameEvent(srv *nui.Server, state *State, ev Event) {
+	g := state.game
+	switch ev.Type {
+	case EVENT_PLAYER_KILLED:
+		if clientID, ok := state.playerClient(ev.Target); ok {
+			srv.Alert(clientID, "Killed", "You were killed. As a ghost, you can still move around and do your tasks.", nil)
+		}
+	case EVENT_MEETING_CALLED:
+		for clientID, playerIdx := range state.clients {
+			screen := makeVoteScreen(state, playerIdx)
+			state.voteScreens[clientID] = screen
+			srv.PushScreen(clientID, screen)
+		}
+	case EVENT_MEETING_ENDED:
+		message := "No one was ejected."
+		if ev.Target >= 0 {
+			message = fmt.Sprintf("%s was ejected. They were %s.", state.playerName(ev.Target),
+				ternaryString(g.Players[ev.Target].Imposter, "the impostor", "not the impostor"))
+		}
+		for clientID, screen := range state.voteScreens {
+			srv.RemoveScreen(clientID, screen)
+			delete(state.voteScreens, clientID)
+		}
+		for clientID := range state.clients {
+			srv.Alert(clientID, "Meeting", message, nil)
+		}
+	case EVENT_GAME_OVER:
+		message := ternaryString(ev.ImpostorsWin, "The impostor wins!", "The crewmates win!")
+		for clientID := range state.clients {
+			srv.Alert(clientID, "Game over", message, nil)
+		}
 	}
 }
 
-// Update all players' screens after changing a player's name.
-// Memory safety: Locks the given state, as well as all screens except for the one corresponding
-// to targetClientID.
-func updateLobbyScreens(srv *nui.Server, state *State, targetClientID int, newName string) {
+// Updates the other players' lobby screens after a player changes
+// their name. Memory safety: Locks the state, and then the screens
+// one at a time, so no screen may be locked by the caller.
+func updateLobbyScreens(srv *nui.Server, state *State, targetClientID int) {
+	state.lobbyLock.Lock()
+	defer state.lobbyLock.Unlock()
+
 	state.RLock()
-	defer state.RUnlock()
+	targetIdx, ok := state.clients[targetClientID]
+	if !ok || state.game != nil {
+		state.RUnlock()
+		return
+	}
+	name := state.players[targetIdx].name
+	// Every list of players has a widget for each player, in order.
+	lists := make(map[int]*nui.VBox)
+	for clientID, players := range state.lobbyPlayers {
+		if clientID != targetClientID {
+			lists[clientID] = players
+		}
+	}
+	state.RUnlock()
 
-	targetIdx := state.clients[targetClientID]
-	for clientID, _ := range state.clients {
+	for clientID, players := range lists {
 		screen, ok := srv.GetScreen(clientID)
 		if !ok {
-			log.Println("warning: impossible situation")
 			continue
 		}
-
-		if clientID != targetClientID {
-			screen.Lock()
-		}
-		widget := screen.Widgets[targetIdx]
-		switch w := widget.(type) {
+		screen.Lock()
+		switch w := players.Children[targetIdx].(type) {
 		case *nui.Label:
-			w.Text = newName
-		case *nui.Entry:
-			w.Text = newName
-		}
-		if clientID != targetClientID {
-			screen.Unlock()
+			w.Text = name
+		case *PlayerWidget:
+			w.Text = name
 		}
+		screen.Unlock()
+		srv.Invalidate(clientID)
 	}
 }
 
 func startGame(srv *nui.Server, state *State) {
 	state.Lock()
 	defer state.Unlock()
-	state.game = NewGame(len(state.players), researchFacility)
+	if state.game != nil {
+		return
+	}
+	seed := state.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	log.Println("game seed:", seed)
+	state.game = NewGame(len(state.players), researchFacility, seed)
+	state.voteScreens = make(map[int]*nui.Screen)
+	state.game.Subscribe(func(ev Event) {
+		handleGameEvent(srv, state, ev)
+	})
+	nav := newNavigator(state.game.Map)
+	for playerIdx, player := range state.players {
+		if player.bot {
+			bot := NewBot(state.game, playerIdx, nav)
+			state.game.Subscribe(bot.HandleEvent)
+			state.bots = append(state.bots, bot)
+		}
+	}
+	state.takeCommands()
+	if state.replayDir != "" {
+		startReplay(state, "research_facility")
+	}
 
-	go func() {
-		var next <-chan time.Time
-		for i := uint(0); true; i++ {
-			next = time.After(time.Millisecond * 50)
+	// The screens stay the same during the whole game,
+	// and only the snapshot they show changes every tick.
+	state.view.Store(state.game.Snapshot())
+	for clientID, playerIdx := range state.clients {
+		srv.SetScreen(clientID, makeGameScreen(state, playerIdx))
+		if playerIdx == state.spectate {
+			recordSpectator(srv, state, clientID)
+		}
+	}
 
+	go func() {
+		ticker := time.NewTicker(TICK_DURATION)
+		defer ticker.Stop()
+		for {
 			state.Lock()
-			state.game.Update(i)
+			commands := state.takeCommands()
+			for _, bot := range state.bots {
+				commands = append(commands, bot.Think(state.game)...)
+			}
+			state.game.Step(commands)
+			if state.replay != nil {
+				if err := state.replay.WriteTick(commands); err != nil {
+					log.Println("error writing replay:", err)
+					closeReplay(state)
+				}
+			}
+			if state.game.Phase == PHASE_OVER {
+				// The replay ends with the tick that ended the game.
+				closeReplay(state)
+				endGame(srv, state)
+				state.Unlock()
+				return
+			}
+			state.view.Store(state.game.Snapshot())
+			for clientID := range state.clients {
+				srv.Invalidate(clientID)
+			}
 			state.Unlock()
 
-			for clientID, playerIdx := range state.clients {
-				srv.SetScreen(clientID, makeGameScreen(state, playerIdx))
+			select {
+			case <-ticker.C:
+			case <-state.ctx.Done():
+				state.Lock()
+				closeReplay(state)
+				state.Unlock()
+				return
 			}
-
-			<-next
 		}
 	}()
 }
 
-func makeLobbyScreen(srv *nui.Server, state *State, clientID int) *nui.Screen {
-	screen := &nui.Screen{}
+// Starts saving a replay of the game that was just created.
+// Memory safety: The state must be locked.
+func startReplay(state *State, mapName string) {
+	replay := &Replay{Seed: state.game.Seed, Map: mapName}
+	for _, player := range state.players {
+		replay.Players = append(replay.Players, player.name)
+	}
+
+	name := fmt.Sprintf("game-%s.replay", time.Now().Format("20060102-150405"))
+	f, err := os.Create(filepath.Join(state.replayDir, name))
+	if err != nil {
+		log.Println("error creating replay:", err)
+		return
+	}
+	state.replay, err = NewReplayWriter(f, replay)
+	if err != nil {
+		log.Println("error writing replay:", err)
+		f.Close()
+	}
+}
+
+// Returns everyone to the lobby after the game is over, without
+// the players who left during the game, so that another can start.
+// Memory safety: The state must be locked.
+func endGame(srv *nui.Server, state *State) {
+	for clientID, screen := range state.voteScreens {
+		srv.RemoveScreen(clientID, screen)
+	}
+	if clientID, ok := state.playerClient(state.spectate); ok {
+		srv.StopRecordingView(clientID)
+	}
+	state.game = nil
+	state.bots = nil
+	state.voteScreens = nil
+	state.view.Store((*GameSnapshot)(nil))
+
+	for idx := len(state.players) - 1; idx >= 0; idx-- {
+		if _, ok := state.playerClient(idx); !ok && !state.players[idx].bot {
+			removePlayer(state, idx)
+		}
+	}
+	resetLobbyScreens(srv, state)
+}
+
+// Finishes the replay of the current game, if one is being saved.
+// Memory safety: The state must be locked.
+func closeReplay(state *State) {
+	if state.replay == nil {
+		return
+	}
+	if err := state.replay.Close(); err != nil {
+		log.Println("error closing replay:", err)
+	}
+	state.replay = nil
+}
+
+// Records the game as the player with the given
+// client ID sees it, at the size of a whole screen.
+func recordSpectator(srv *nui.Server, state *State, clientID int) {
+	name := fmt.Sprintf("spectator-%s.cast", time.Now().Format("20060102-150405"))
+	f, err := os.Create(filepath.Join(state.recordDir, name))
+	if err != nil {
+		log.Println("error creating spectator recording:", err)
+		return
+	}
+	if err := srv.RecordView(clientID, f, srv.TermWidth, srv.TermHeight); err != nil {
+		log.Println("error recording spectator view:", err)
+		f.Close()
+	}
+}
+
+// Shows the players in the lobby, with buttons for the host to
+// start the game. Returns the screen and its list of players.
+func makeLobbyScreen(srv *nui.Server, state *State, clientID int) (*nui.Screen, *nui.VBox) {
+	isHost := state.clients[clientID] == state.host()
+	playerClients := make(map[int]int)
+	for clientID, playerIdx := range state.clients {
+		playerClients[playerIdx] = clientID
+	}
+
+	players := &nui.VBox{}
 	for playerIdx, player := range state.players {
 		if state.clients[clientID] != playerIdx {
-			format := nui.Format{Fg: nui.Color(playerIdx + 61), Bg: nui.Black}
-			label := &nui.Label{X: 8, Y: 5 + uint16(playerIdx), Format: format, Text: player.name}
-			screen.Widgets = append(screen.Widgets, label)
+			format := nui.Format{Fg: playerColor(playerIdx), Bg: nui.Black}
+			label := nui.Label{Format: format, Text: player.name}
+			if !isHost || player.bot {
+				players.Children = append(players.Children, &label)
+				continue
+			}
+
+			targetID := playerClients[playerIdx]
+			widget := &PlayerWidget{Label: label}
+			widget.KickHandler = func() {
+				srv.Disconnect(targetID, "You were kicked by the host.")
+			}
+			widget.BanHandler = func() {
+				srv.Confirm(clientID, "Ban", "Ban "+widget.Text+"? They will not be able to join again.", func(ok bool) {
+					if !ok {
+						return
+					}
+					if addr, ok := srv.RemoteAddr(targetID); ok {
+						state.bans.Store(addrHost(addr), true)
+					}
+					srv.Disconnect(targetID, "You were banned by the host.")
+				})
+			}
+			players.Children = append(players.Children, widget)
 		} else {
 			playerIdx := playerIdx
-			format := nui.Format{Fg: nui.Color(playerIdx + 61), Bg: nui.Black, Bold: true}
+			format := nui.Format{Fg: playerColor(playerIdx), Bg: nui.Black, Bold: true}
 			entry := &nui.Entry{
-				X: 8, Y: 5 + uint16(playerIdx), Format: format, Text: player.name, Max: 16,
+				Format: format, Text: player.name, Max: 16,
 
 				HandleInput: func(name string) {
-					state.players[playerIdx].name = name
-					updateLobbyScreens(srv, state, clientID, name)
+					state.Lock()
+					if idx, ok := state.clients[clientID]; ok && state.game == nil {
+						state.players[idx].name = name
+					}
+					state.Unlock()
+
+					// This screen is locked, so the others
+					// are updated once it is unlocked.
+					go updateLobbyScreens(srv, state, clientID)
 				},
 			}
-			screen.Widgets = append(screen.Widgets, entry)
-			screen.Focus = playerIdx
+			players.Children = append(players.Children, entry)
 		}
 	}
 
 	headerFormat := nui.Format{Fg: nui.LightWhite, Bg: nui.Black, Underline: true}
-	screen.Widgets = append(screen.Widgets, &nui.Label{X: 8, Y: 4, Format: headerFormat, Text: fmt.Sprintf("Players: %d", len(state.players))})
+	header := &nui.Label{Format: headerFormat, Text: fmt.Sprintf("Players: %d", len(state.players))}
 
-	if state.clients[clientID] == 0 { // host
-		screen.Widgets = append(screen.Widgets, &nui.Label{
-			X: 64, Y: 4, Format: headerFormat, Text: "Host",
-		})
-		screen.Widgets = append(screen.Widgets, &nui.Button{
-			X: 62, Y: 6, Format: nui.Format{Bg: nui.Blue, Fg: nui.LightWhite}, Text: "Start",
+	panel := &nui.VBox{Spacing: 1}
+	if isHost {
+		panel.Children = append(panel.Children, &nui.Padding{Left: 2, Child: &nui.Label{Format: headerFormat, Text: "Host"}})
+		panel.Children = append(panel.Children, &nui.Button{
+			Format: nui.Format{Bg: nui.Blue, Fg: nui.LightWhite}, Text: "Start",
 
 			HandleClick: func() {
 				fmt.Println("game starting")
 				startGame(srv, state)
 			},
 		})
+		panel.Children = append(panel.Children, &nui.Button{
+			Format: nui.Format{Bg: nui.Blue, Fg: nui.LightWhite}, Text: "Add bot",
+
+			HandleClick: func() {
+				state.Lock()
+				defer state.Unlock()
+				if state.game != nil || len(state.players) >= MAX_PLAYERS {
+					return
+				}
+				nbots := 0
+				for _, player := range state.players {
+					if player.bot {
+						nbots++
+					}
+				}
+				state.players = append(state.players, Player{name: fmt.Sprintf("Bot %d", nbots+1), bot: true})
+				resetLobbyScreens(srv, state)
+			},
+		})
+		panel.Children = append(panel.Children, &nui.Button{
+			Format: nui.Format{Bg: nui.Blue, Fg: nui.LightWhite}, Text: "Remove bot",
+
+			HandleClick: func() {
+				state.Lock()
+				defer state.Unlock()
+				if state.game != nil {
+					return
+				}
+				for idx := len(state.players) - 1; idx >= 0; idx-- {
+					if state.players[idx].bot {
+						removePlayer(state, idx)
+						resetLobbyScreens(srv, state)
+						return
+					}
+				}
+			},
+		})
 	}
 
-	return screen
-}
+	colors := &nui.Button{
+		Format: nui.Format{Bg: nui.LightBlack, Fg: nui.LightWhite}, Text: "Colors: " + srv.GetColorMode(clientID).String(),
+	}
+	colors.HandleClick = func() {
+		mode := (srv.GetColorMode(clientID) + 1) % (nui.TrueColor + 1)
+		srv.SetColorMode(clientID, mode)
+		colors.Text = "Colors: " + mode.String()
+	}
+	panel.Children = append(panel.Children, colors)
+
+	layout := &nui.VBox{Spacing: 1, Children: []nui.Widget{
+		&nui.HBox{Spacing: 6, Children: []nui.Widget{
+			&nui.VBox{Children: []nui.Widget{header, players}},
+			panel,
+		}},
+	}}
+	if isHost {
+		layout.Children = append(layout.Children, &nui.Label{
+			Format: nui.Format{Fg: nui.LightBlack, Bg: nui.Black},
+			Text:   "Tab to a player, then press k to kick or b to ban",
+		})
+	}
 
-func main() {
-	rand.Seed(time.Now().UnixNano())
+	// The player's own entry is the first
+	// widget that can be focused.
+	root := &nui.Center{Child: &nui.Frame{
+		Title:  "Lobby",
+		Format: nui.Format{Fg: nui.LightWhite, Bg: nui.Black},
+		Child:  &nui.Padding{Top: 1, Right: 2, Bottom: 1, Left: 2, Child: layout},
+	}}
+	root.Focus(true)
+	return &nui.Screen{Widgets: []nui.Widget{root}}, players
+}
 
-	var state = State{
-		clients: make(map[int]int),
+// Removes a player from the lobby.
+// Memory safety: The state must be locked.
+func removePlayer(state *State, idx int) {
+	state.players = append(state.players[:idx], state.players[idx+1:]...)
+	for clientID, playerIdx := range state.clients {
+		if playerIdx > idx {
+			state.clients[clientID] = playerIdx - 1
+		}
 	}
+}
 
-	ln, err := net.Listen("tcp", ":6567")
-	if err != nil {
-		panic(err)
+// Gives every client a new lobby screen after players join or leave.
+// Memory safety: The state must be locked.
+func resetLobbyScreens(srv *nui.Server, state *State) {
+	state.lobbyPlayers = make(map[int]*nui.VBox)
+	for clientID, _ := range state.clients {
+		screen, players := makeLobbyScreen(srv, state, clientID)
+		state.lobbyPlayers[clientID] = players
+		srv.SetScreen(clientID, screen)
 	}
-	log.Println("localhost:6567")
+}
 
-	srv := nui.NewServer(ln)
-	srv.TermWidth = 128
-	srv.TermHeight = 32 + 4
+// Lets clients join the lobby and play games on the server.
+func handleClients(srv *nui.Server, state *State) {
 	srv.HandleConnect = func(clientID int) {
 		log.Printf("event: connect [%d]\n", clientID)
 
 		state.Lock()
 		defer state.Unlock()
 
-		if state.game == nil {
-			state.clients[clientID] = len(state.players)
-			state.players = append(state.players, Player{})
-
-			screen := makeLobbyScreen(srv, &state, clientID)
-			srv.SetScreen(clientID, screen)
-
-			// create new screens for everyone
-			for clientID, _ := range state.clients {
-				screen := makeLobbyScreen(srv, &state, clientID)
-				srv.SetScreen(clientID, screen)
-			}
-		} else {
-			screen := &nui.Screen{
-				Widgets: []nui.Widget{&nui.Label{X: 0, Y: 0, Format: nui.Format{Fg: nui.LightWhite, Bg: nui.Red}, Text: "Game has begun. Please join later."}},
+		if addr, ok := srv.RemoteAddr(clientID); ok {
+			if _, banned := state.bans.Load(addrHost(addr)); banned {
+				srv.Disconnect(clientID, "You are banned from this server.")
+				return
 			}
-			srv.SetScreen(clientID, screen)
+		}
 
-			// TODO: Print error message and close the connection
+		if state.game != nil {
+			srv.Disconnect(clientID, "Game has begun. Please join later.")
+		} else if len(state.players) >= MAX_PLAYERS {
+			srv.Disconnect(clientID, "The lobby is full. Please join later.")
+		} else {
+			state.clients[clientID] = len(state.players)
+			state.players = append(state.players, Player{})
+			resetLobbyScreens(srv, state)
 		}
 	}
 	srv.HandleDisconnect = func(clientID int) {
@@ -225,26 +615,133 @@ func main() {
 
 		idx, ok := state.clients[clientID]
 		if !ok {
-			log.Println("warning: non-existant player disconnected")
+			// Rejected clients never became players.
 			return
 		}
 
 		delete(state.clients, clientID)
 		if state.game == nil {
-			if len(state.players) > 1 {
-				state.players[idx] = state.players[len(state.clients)-1]
-			}
-			state.players = state.players[0 : len(state.players)-1]
-
-			// create new screens for everyone
-			for clientID, _ := range state.clients {
-				screen := makeLobbyScreen(srv, &state, clientID)
-				srv.SetScreen(clientID, screen)
-			}
+			removePlayer(state, idx)
+			resetLobbyScreens(srv, state)
 		} else {
-			state.game.Kill(idx)
-			state.game.Players[idx].Disconnected = true
+			state.sendCommand(Command{Player: idx, Type: CMD_LEAVE})
+		}
+	}
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		loadtest(os.Args[2:])
+		return
+	}
+
+	addr := flag.String("addr", ":6567", "address to accept plain TCP connections on, or empty to disable them")
+	unixPath := flag.String("unix", "", "path of a Unix socket to accept connections on")
+	tlsAddr := flag.String("tls", "", "address to accept TLS connections on; requires -tls-cert and -tls-key")
+	tlsCert := flag.String("tls-cert", "", "path of the TLS certificate")
+	tlsKey := flag.String("tls-key", "", "path of the TLS private key")
+	sshAddr := flag.String("ssh", "", "address to accept SSH connections on, such as :2222 (default: SSH is disabled)")
+	hostKey := flag.String("hostkey", "", "path of the SSH host key, created if it does not exist (default: a new key every run)")
+	httpAddr := flag.String("http", "", "address to serve the browser client on, such as :8080 (default: the browser client is disabled)")
+	recordDir := flag.String("record", "", "directory to save an asciicast recording of every client to")
+	spectate := flag.Int("spectate", -1, "index of a player whose view of the game is recorded to the -record directory (0 is the host)")
+	replayDir := flag.String("replays", "", "directory to save a replay of every game to")
+	replayPath := flag.String("replay", "", "path of a replay to show to every client instead of hosting a game")
+	seed := flag.Int64("seed", 0, "seed of the game, to reproduce one (default: a random seed, which is logged)")
+	flag.Parse()
+
+	if *spectate >= 0 && *recordDir == "" {
+		log.Fatalln("-spectate requires -record")
+	}
+	for _, dir := range []string{*recordDir, *replayDir} {
+		if dir == "" {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			panic(err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var state = State{
+		clients:   make(map[int]int),
+		ctx:       ctx,
+		recordDir: *recordDir,
+		spectate:  *spectate,
+		seed:      *seed,
+		replayDir: *replayDir,
+	}
+
+	var listeners []nui.Listener
+	if *addr != "" {
+		ln, err := net.Listen("tcp", *addr)
+		if err != nil {
+			panic(err)
+		}
+		log.Println("tcp", *addr)
+		listeners = append(listeners, nui.NewListener(ln))
+	}
+	if *unixPath != "" {
+		ln, err := net.Listen("unix", *unixPath)
+		if err != nil {
+			panic(err)
+		}
+		log.Println("unix", *unixPath)
+		listeners = append(listeners, nui.NewListener(ln))
+	}
+	if *tlsAddr != "" {
+		cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+		if err != nil {
+			panic(err)
+		}
+		ln, err := tls.Listen("tcp", *tlsAddr, &tls.Config{Certificates: []tls.Certificate{cert}})
+		if err != nil {
+			panic(err)
+		}
+		log.Println("tls", *tlsAddr)
+		listeners = append(listeners, nui.NewListener(ln))
+	}
+	if *sshAddr != "" {
+		config, err := nui.NewSSHConfig(*hostKey)
+		if err != nil {
+			panic(err)
+		}
+		ln, err := net.Listen("tcp", *sshAddr)
+		if err != nil {
+			panic(err)
 		}
+		log.Println("ssh", *sshAddr)
+		listeners = append(listeners, nui.NewSSHListener(ln, config))
+	}
+	if *httpAddr != "" {
+		ln, err := net.Listen("tcp", *httpAddr)
+		if err != nil {
+			panic(err)
+		}
+		log.Println("http", *httpAddr)
+		listeners = append(listeners, nui.NewWebListener(ln))
+	}
+
+	srv := nui.NewServer(listeners...)
+	srv.TermWidth = 128
+	srv.TermHeight = 32 + 4
+	srv.ColorMode = nui.Colors256
+	srv.RecordDir = *recordDir
+
+	if *replayPath != "" {
+		if err := serveReplay(ctx, srv, *replayPath); err != nil {
+			log.Println("error:", err)
+		}
+		log.Println("server stopped")
+		return
+	}
+
+	handleClients(srv, &state)
+
+	if err := srv.Run(ctx); err != nil {
+		log.Println("error:", err)
 	}
-	srv.Run()
+	log.Println("server stopped")
 }
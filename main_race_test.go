@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allen-b1/sus-tux/nui"
+	"github.com/allen-b1/sus-tux/nui/nuitest"
+)
+
+// Number of clients that play the game in TestManyClients
+const RACE_CLIENTS = 10
+
+// Runs fn for every client at the same time.
+func eachClient(clients []*nuitest.Client, fn func(i int, c *nuitest.Client)) {
+	var wg sync.WaitGroup
+	for i, c := range clients {
+		wg.Add(1)
+		go func(i int, c *nuitest.Client) {
+			defer wg.Done()
+			fn(i, c)
+		}(i, c)
+	}
+	wg.Wait()
+}
+
+// Waits until every client shows text.
+func waitAll(t *testing.T, clients []*nuitest.Client, text string) {
+	t.Helper()
+	for i, c := range clients {
+		if !c.WaitText(text) {
+			t.Fatalf("client %d does not show %q:\n%s", i, text, c.VT())
+		}
+	}
+}
+
+// Plays a game from the lobby through a meeting and back to the
+// lobby with many clients sending input at once, so that running
+// it with -race finds data races between them and the game loop.
+func TestManyClients(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	state := &State{clients: make(map[int]int), ctx: ctx, spectate: -1}
+	srv := nui.NewServer()
+	srv.TermWidth, srv.TermHeight = MAP_WIDTH, MAP_HEIGHT+4
+	handleClients(srv, state)
+	l := nuitest.Serve(srv)
+	defer srv.Shutdown(context.Background())
+	defer l.Close()
+
+	// Clients join one at a time, so that client i has ID i.
+	var clients []*nuitest.Client
+	for i := 0; i < RACE_CLIENTS; i++ {
+		c, err := l.Connect(nui.Terminal{Width: MAP_WIDTH, Height: MAP_HEIGHT + 4, Type: "xterm-direct"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if !c.WaitText(fmt.Sprintf("Players: %d", i+1)) {
+			t.Fatalf("client %d did not join the lobby:\n%s", i, c.VT())
+		}
+		clients = append(clients, c)
+	}
+
+	// Everyone types their name at once, and sees everyone else's.
+	eachClient(clients, func(i int, c *nuitest.Client) {
+		for _, ch := range fmt.Sprintf("p%02d", i) {
+			c.Send(string(ch))
+			time.Sleep(time.Millisecond)
+		}
+	})
+	for i := range clients {
+		waitAll(t, clients, fmt.Sprintf("p%02d", i))
+	}
+
+	x, y, ok := clients[0].VT().Find("Start")
+	if !ok {
+		t.Fatalf("host has no start button:\n%s", clients[0].VT())
+	}
+	clients[0].Click(x, y, nui.MouseLeft)
+	waitAll(t, clients, "Role:")
+
+	// Everyone starts in the same place, so the impostor
+	// can kill someone at once if its cooldown is over.
+	impostor := -1
+	state.Lock()
+	for clientID, playerIdx := range state.clients {
+		if state.game.Players[playerIdx].Imposter {
+			impostor = clientID
+			state.game.Players[playerIdx].KillCooldown = 0
+		}
+	}
+	state.Unlock()
+
+	clients[impostor].Send("k")
+	victim := -1
+	for victim < 0 {
+		if !clients[impostor].WaitTextTimeout("Kill in", nuitest.DefaultTimeout) {
+			t.Fatalf("impostor did not kill anyone:\n%s", clients[impostor].VT())
+		}
+		for i, c := range clients {
+			if _, _, ok := c.VT().Find("You were killed."); ok {
+				victim = i
+			}
+		}
+	}
+
+	// Someone else reports the body, and everyone skips
+	// their vote at once, so that the game goes on.
+	reporter := 0
+	for reporter == impostor || reporter == victim {
+		reporter++
+	}
+	clients[reporter].Send("r")
+	waitAll(t, clients, "found the body")
+	eachClient(clients, func(i int, c *nuitest.Client) {
+		if x, y, ok := c.VT().Find("Skip vote"); ok {
+			c.Click(x, y, nui.MouseLeft)
+			c.Click(x, y, nui.MouseLeft)
+		}
+	})
+	waitAll(t, clients, "No one was ejected.")
+
+	// Everyone closes the alerts and walks around while resizing.
+	eachClient(clients, func(i int, c *nuitest.Client) {
+		c.Send("\n\n")
+		r := rand.New(rand.NewSource(int64(i)))
+		for j := 0; j < 40; j++ {
+			c.Send(string("wasdq"[r.Intn(5)]))
+			if j%10 == 0 {
+				c.Resize(MAP_WIDTH+r.Intn(20), MAP_HEIGHT+4+r.Intn(10))
+			}
+			time.Sleep(2 * time.Millisecond)
+		}
+	})
+
+	// The game is over once the impostor leaves,
+	// and everyone else returns to the lobby.
+	clients[impostor].Close()
+	var remaining []*nuitest.Client
+	for i, c := range clients {
+		if i != impostor {
+			remaining = append(remaining, c)
+		}
+	}
+	// The alert is shown over the middle of the lobby.
+	waitAll(t, remaining, "Game over")
+	eachClient(remaining, func(i int, c *nuitest.Client) {
+		c.Send("\n")
+	})
+	waitAll(t, remaining, fmt.Sprintf("Players: %d", RACE_CLIENTS-1))
+
+	state.RLock()
+	defer state.RUnlock()
+	if state.game != nil {
+		t.Error("game did not end")
+	}
+	for i, c := range remaining {
+		if strings.Contains(c.VT().String(), "Role:") {
+			t.Errorf("client %d still shows the game:\n%s", i, c.VT())
+		}
+	}
+}
@@ -15,25 +15,32 @@ type MapWidget struct {
 	PlayerColor nui.Color
 	Map         *Map
 
-	Player *GamePlayer
+	// If nil, the map is shown centered on CenterX and
+	// CenterY, and shows who the impostors are.
+	Player           *GamePlayer
+	CenterX, CenterY uint32
 	// Readonly
 	Players []GamePlayer
 
-	// Required
-	KillHandler func()
+	// Required unless Player is nil. Commands are
+	// sent without the index of the player.
+	CommandHandler func(cmd Command)
 }
 
 func (m *MapWidget) Draw(buf *nui.Buffer) {
 	// Position of the map
 	// where the top-left corner is
-	offX := int32(m.Player.X) - MAP_WIDTH/2
-	offY := int32(m.Player.Y) - MAP_HEIGHT/2
+	centerX, centerY := m.CenterX, m.CenterY
+	if m.Player != nil {
+		centerX, centerY = m.Player.X, m.Player.Y
+	}
+	offX := int32(centerX) - MAP_WIDTH/2
+	offY := int32(centerY) - MAP_HEIGHT/2
 
-	for x := m.X; x < m.X+MAP_WIDTH; x++ {
-		for y := m.Y; y < m.Y+MAP_HEIGHT; y++ {
-			idx := buf.Index(x, y)
-			mapX := int32(x-m.X) + offX
-			mapY := int32(y-m.Y) + offY
+	for x := 0; x < MAP_WIDTH; x++ {
+		for y := 0; y < MAP_HEIGHT; y++ {
+			mapX := int32(x) + offX
+			mapY := int32(y) + offY
 
 			var ch byte
 			if mapX >= 1 && mapX < int32(m.Map.Width)-1 &&
@@ -43,17 +50,34 @@ func (m *MapWidget) Draw(buf *nui.Buffer) {
 				ch = 0
 			}
 
-			buf.Chars[idx] = ch
+			var format nui.Format
 			if ch == 0 {
-				buf.Chars[idx] = ' '
-				buf.Formats[idx] = nui.Format{Bg: nui.LightBlack, Fg: nui.Black}
+				ch = ' '
+				format = nui.Format{Bg: nui.LightBlack, Fg: nui.Black}
 			} else if ch == ' ' {
-				buf.Formats[idx] = nui.Format{Bg: nui.LightWhite, Fg: nui.LightWhite}
+				format = nui.Format{Bg: nui.LightWhite, Fg: nui.LightWhite}
 			} else if ch == '+' {
-				buf.Formats[idx] = nui.Format{Bg: nui.LightBlack, Fg: nui.Black}
+				format = nui.Format{Bg: nui.LightBlack, Fg: nui.Black}
 			} else {
-				buf.Formats[idx] = nui.Format{Bg: nui.Magenta, Fg: nui.LightWhite}
+				format = nui.Format{Bg: nui.Magenta, Fg: nui.LightWhite}
 			}
+			buf.SetCell(int(m.X)+x, int(m.Y)+y, ch, format)
+		}
+	}
+
+	// Stations of the player's tasks that are not done yet
+	if m.Player != nil {
+		for _, task := range m.Player.Tasks {
+			if task.Done {
+				continue
+			}
+			station := m.Map.Stations[task.Station]
+			viewX := int32(station.X) - offX
+			viewY := int32(station.Y) - offY
+			if viewX < 0 || viewY < 0 || viewX >= MAP_WIDTH || viewY >= MAP_HEIGHT {
+				continue
+			}
+			buf.SetCell(int(m.X)+int(viewX), int(m.Y)+int(viewY), station.Name, nui.Format{Bg: nui.Yellow, Fg: nui.Black, Bold: true})
 		}
 	}
 
@@ -61,6 +85,10 @@ func (m *MapWidget) Draw(buf *nui.Buffer) {
 		mapX := player.X
 		mapY := player.Y
 		if player.Dead {
+			if !player.Body {
+				// Ghosts and ejected players are not shown
+				continue
+			}
 			mapX = player.Corpse[0]
 			mapY = player.Corpse[1]
 		}
@@ -71,43 +99,62 @@ func (m *MapWidget) Draw(buf *nui.Buffer) {
 			continue
 		}
 
-		idx := buf.Index(uint16(viewX)+m.X, uint16(viewY)+m.Y)
-		buf.Chars[idx] = ternaryByte(player.Dead, 'x', 'o')
-		if nui.Color(playerIdx+1) != m.PlayerColor {
-			buf.Formats[idx] = nui.Format{Fg: nui.Color(playerIdx + 1), Bg: nui.LightWhite}
-			if m.Player.Imposter && !m.Player.Dead && !player.Dead {
-				if (m.Player.X-player.X)*(m.Player.X-player.X)+(m.Player.Y-player.Y)*(m.Player.Y-player.Y) <= KILL_RADIUS*KILL_RADIUS {
-					buf.Formats[idx].Bg = nui.LightRed
+		var format nui.Format
+		if m.Player == nil {
+			format = nui.Format{Fg: playerColor(playerIdx), Bg: nui.LightWhite}
+			if player.Imposter {
+				format.Bg = nui.LightRed
+			}
+		} else if playerColor(playerIdx) != m.PlayerColor {
+			format = nui.Format{Fg: playerColor(playerIdx), Bg: nui.LightWhite}
+			if m.Player.Imposter && !m.Player.Dead && !player.Dead && !player.Imposter {
+				if distance2(m.Player.X, m.Player.Y, player.X, player.Y) <= KILL_RADIUS*KILL_RADIUS {
+					format.Bg = nui.LightRed
 				}
 			}
 		} else {
 			if !player.Dead {
-				buf.Formats[idx] = nui.Format{Fg: nui.LightWhite, Bg: m.PlayerColor, Bold: true}
+				format = nui.Format{Fg: nui.LightWhite, Bg: m.PlayerColor, Bold: true}
 			} else {
-				buf.Formats[idx] = nui.Format{Fg: m.PlayerColor, Bg: nui.LightWhite}
+				format = nui.Format{Fg: m.PlayerColor, Bg: nui.LightWhite}
 			}
 		}
+		buf.SetCell(int(m.X)+int(viewX), int(m.Y)+int(viewY), ternaryByte(player.Dead, 'x', 'o'), format)
+	}
+
+	if m.Player != nil {
+		buf.SetCursor(int(m.X)+MAP_WIDTH/2, int(m.Y)+MAP_HEIGHT/2, nui.Format{Bg: nui.LightWhite, Fg: m.PlayerColor})
 	}
+}
+
+func (m *MapWidget) Size() (uint16, uint16) {
+	return MAP_WIDTH, MAP_HEIGHT
+}
 
-	buf.CursorX = m.X + MAP_WIDTH/2
-	buf.CursorY = m.Y + MAP_HEIGHT/2
-	buf.CursorFormat = nui.Format{Bg: nui.LightWhite, Fg: m.PlayerColor}
+func (m *MapWidget) Place(area nui.Rect) {
+	m.X, m.Y = area.X, area.Y
 }
 
 func (m *MapWidget) Focus(focus bool) {}
 
 func (m *MapWidget) Keypress(ch byte) {
 	if ch == 'w' {
-		m.Player.Direction = [2]int8{0, -1}
+		m.CommandHandler(Command{Type: CMD_MOVE, Direction: [2]int8{0, -1}})
 	} else if ch == 's' {
-		m.Player.Direction = [2]int8{0, 1}
+		m.CommandHandler(Command{Type: CMD_MOVE, Direction: [2]int8{0, 1}})
 	} else if ch == 'a' {
-		m.Player.Direction = [2]int8{-1, 0}
+		m.CommandHandler(Command{Type: CMD_MOVE, Direction: [2]int8{-1, 0}})
 	} else if ch == 'd' {
-		m.Player.Direction = [2]int8{1, 0}
+		m.CommandHandler(Command{Type: CMD_MOVE, Direction: [2]int8{1, 0}})
 	} else if ch == 'q' {
-		m.Player.Direction = [2]int8{0, 0}
+		m.CommandHandler(Command{Type: CMD_MOVE, Direction: [2]int8{0, 0}})
 	} else if ch == 'k' {
-		m.KillHandler()
+		m.CommandHandler(Command{Type: CMD_KILL})
+	} else if ch == 'r' {
+		m.CommandHandler(Command{Type: CMD_REPORT})
+	} else if ch == 'v' {
+		m.CommandHandler(Command{Type: CMD_VENT})
+	} else if ch == 'e' {
+		m.CommandHandler(Command{Type: CMD_TASK})
 	}
 }
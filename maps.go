@@ -7,6 +7,18 @@ import (
 type Map struct {
 	Data  []byte
 	Width uint32
+
+	// Lettered places on the map, such as vents
+	// and where tasks are done.
+	Stations []Station
+}
+
+// Vents are stations with this letter.
+const VENT = 'v'
+
+type Station struct {
+	Name byte
+	X, Y uint32
 }
 
 func (m *Map) Height() uint32 {
@@ -41,6 +53,10 @@ func NewMap(data string) *Map {
 			m.Data[(3*y+2)*int(m.Width)+3*x] = ' '
 			m.Data[(3*y+2)*int(m.Width)+3*x+1] = ternaryByte(wallBottom, c, ' ')
 			m.Data[(3*y+2)*int(m.Width)+3*x+2] = ' '
+
+			if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+				m.Stations = append(m.Stations, Station{Name: c, X: uint32(3*x + 1), Y: uint32(3*y + 1)})
+			}
 		}
 	}
 
@@ -0,0 +1,133 @@
+package nui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Type Recorder writes terminal output to an asciicast v2 file,
+// which can be played back with asciinema. Its methods are safe
+// to call concurrently; errors after the header is written are
+// logged and stop the recording.
+type Recorder struct {
+	w     io.WriteCloser
+	start time.Time
+
+	failed bool
+	lock   sync.Mutex
+}
+
+type asciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     uint16            `json:"width"`
+	Height    uint16            `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Starts a recording of a terminal, writing to w,
+// which is closed when the recording is closed.
+func NewRecorder(w io.WriteCloser, term Terminal) (*Recorder, error) {
+	r := &Recorder{w: w, start: time.Now()}
+
+	header := asciicastHeader{
+		Version:   2,
+		Width:     term.Width,
+		Height:    term.Height,
+		Timestamp: r.start.Unix(),
+	}
+	if term.Type != "" {
+		header.Env = map[string]string{"TERM": term.Type}
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) event(code string, data string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.failed {
+		return
+	}
+
+	t := time.Since(r.start).Seconds()
+	line, err := json.Marshal([]interface{}{t, code, data})
+	if err == nil {
+		_, err = r.w.Write(append(line, '\n'))
+	}
+	if err != nil {
+		log.Println("error recording:", err)
+		r.failed = true
+	}
+}
+
+// Records output sent to the terminal.
+func (r *Recorder) Output(data string) {
+	r.event("o", data)
+}
+
+// Records the terminal being resized.
+func (r *Recorder) Resize(width uint16, height uint16) {
+	r.event("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.failed = true
+	return r.w.Close()
+}
+
+// A connection that only records what is written to it,
+// used to record a client's screens at a different size.
+type recordConn struct {
+	rec  *Recorder
+	term Terminal
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *recordConn) Write(p []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	c.rec.Output(string(p))
+	return len(p), nil
+}
+
+// Blocks until the connection is closed.
+func (c *recordConn) Read(p []byte) (int, error) {
+	<-c.closed
+	return 0, io.EOF
+}
+
+func (c *recordConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		err = c.rec.Close()
+	})
+	return err
+}
+
+func (c *recordConn) Terminal() Terminal {
+	return c.term
+}
+
+func (c *recordConn) RemoteAddr() net.Addr {
+	return nil
+}
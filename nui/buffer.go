@@ -0,0 +1,131 @@
+package nui
+
+// Drawing helpers for widgets. Coordinates are relative to the view
+// that is drawn to, and anything outside of it is silently clipped.
+
+// Returns the intersection of two rectangles.
+func (r Rect) intersect(o Rect) Rect {
+	x0, y0 := maxInt(int(r.X), int(o.X)), maxInt(int(r.Y), int(o.Y))
+	x1 := minInt(int(r.X)+int(r.Width), int(o.X)+int(o.Width))
+	y1 := minInt(int(r.Y)+int(r.Height), int(o.Y)+int(o.Height))
+	if x1 <= x0 || y1 <= y0 {
+		return Rect{uint16(x0), uint16(y0), 0, 0}
+	}
+	return Rect{uint16(x0), uint16(y0), uint16(x1 - x0), uint16(y1 - y0)}
+}
+
+// Returns true if the point is inside the rectangle.
+func (r Rect) Contains(x int, y int) bool {
+	return x >= int(r.X) && y >= int(r.Y) && x < int(r.X)+int(r.Width) && y < int(r.Y)+int(r.Height)
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Returns the buffer that views draw into, and the
+// area of it that this buffer is allowed to draw to.
+func (b *Buffer) root() (*Buffer, Rect) {
+	if b.parent != nil {
+		return b.parent, b.clip
+	}
+	return b, Rect{0, 0, b.Width, b.Height()}
+}
+
+// Returns the area that can be drawn to, relative to the view.
+func (b *Buffer) Bounds() Rect {
+	_, clip := b.root()
+	return Rect{clip.X - b.originX, clip.Y - b.originY, clip.Width, clip.Height}
+}
+
+func (b *Buffer) view(area Rect, originX uint16, originY uint16) *Buffer {
+	root, clip := b.root()
+	area.X += b.originX
+	area.Y += b.originY
+	return &Buffer{
+		Chars:   root.Chars,
+		Formats: root.Formats,
+		Width:   root.Width,
+		parent:  root,
+		clip:    clip.intersect(area),
+		originX: originX,
+		originY: originY,
+	}
+}
+
+// Returns a view of the buffer that only draws inside
+// the given area, using the same coordinates.
+func (b *Buffer) Clip(area Rect) *Buffer {
+	return b.view(area, b.originX, b.originY)
+}
+
+// Returns a view of the buffer that only draws inside the
+// given area, with (0, 0) at the top-left corner of the area.
+func (b *Buffer) Sub(area Rect) *Buffer {
+	return b.view(area, b.originX+area.X, b.originY+area.Y)
+}
+
+// Returns the index of a cell in Chars and
+// Formats, or false if it is outside the view.
+func (b *Buffer) cell(x int, y int) (int, bool) {
+	root, clip := b.root()
+	x += int(b.originX)
+	y += int(b.originY)
+	if !clip.Contains(x, y) {
+		return 0, false
+	}
+	return root.Index(uint16(x), uint16(y)), true
+}
+
+// Sets a single cell.
+func (b *Buffer) SetCell(x int, y int, ch byte, format Format) {
+	if idx, ok := b.cell(x, y); ok {
+		b.Chars[idx] = ch
+		b.Formats[idx] = format
+	}
+}
+
+// Returns a single cell, or false if it is outside the view.
+func (b *Buffer) Cell(x int, y int) (byte, Format, bool) {
+	if idx, ok := b.cell(x, y); ok {
+		return b.Chars[idx], b.Formats[idx], true
+	}
+	return 0, Format{}, false
+}
+
+// Writes a string on a single line, starting at (x, y).
+func (b *Buffer) WriteString(x int, y int, s string, format Format) {
+	for i := 0; i < len(s); i++ {
+		b.SetCell(x+i, y, s[i], format)
+	}
+}
+
+// Fills an area with a character.
+func (b *Buffer) Fill(area Rect, ch byte, format Format) {
+	for y := int(area.Y); y < int(area.Y)+int(area.Height); y++ {
+		for x := int(area.X); x < int(area.X)+int(area.Width); x++ {
+			b.SetCell(x, y, ch, format)
+		}
+	}
+}
+
+// Moves the cursor of the whole buffer. Positions
+// outside the buffer are moved to its nearest edge.
+func (b *Buffer) SetCursor(x int, y int, format Format) {
+	root, _ := b.root()
+	x += int(b.originX)
+	y += int(b.originY)
+	root.CursorX = uint16(minInt(maxInt(x, 0), maxInt(int(root.Width)-1, 0)))
+	root.CursorY = uint16(minInt(maxInt(y, 0), maxInt(int(root.Height())-1, 0)))
+	root.CursorFormat = format
+}
@@ -0,0 +1,84 @@
+package nui
+
+import (
+	"io"
+	"net"
+	"strings"
+)
+
+// Type Terminal describes a client's terminal.
+// Fields are left empty if they are unknown.
+type Terminal struct {
+	Width  uint16
+	Height uint16
+
+	// Values of $TERM and $COLORTERM
+	Type      string
+	ColorTerm string
+}
+
+// Largest terminal size that is drawn. Larger terminals are drawn
+// at this size, so that clients cannot make the server allocate
+// arbitrarily large buffers.
+const maxWidth, maxHeight = 512, 256
+
+// Returns a terminal size that a client reported, limited to
+// at least 1 by 1 and at most maxWidth by maxHeight.
+func clampSize(width int, height int) (uint16, uint16) {
+	clamp := func(n int, max int) uint16 {
+		if n < 1 {
+			return 1
+		} else if n > max {
+			return uint16(max)
+		}
+		return uint16(n)
+	}
+	return clamp(width, maxWidth), clamp(height, maxHeight)
+}
+
+// Guesses which colors the terminal supports
+// from its type. Returns false if it is unknown.
+func (t Terminal) ColorMode() (ColorMode, bool) {
+	if t.ColorTerm == "truecolor" || t.ColorTerm == "24bit" || strings.HasSuffix(t.Type, "-direct") {
+		return TrueColor, true
+	}
+	if strings.Contains(t.Type, "256color") {
+		return Colors256, true
+	}
+	if t.Type != "" {
+		return Colors16, true
+	}
+	return Colors16, false
+}
+
+// Type Conn represents a connection to a client's terminal.
+type Conn interface {
+	io.ReadWriteCloser
+
+	// Returns what is known about the client's
+	// terminal when it connected.
+	Terminal() Terminal
+
+	// Returns the address of the client.
+	RemoteAddr() net.Addr
+}
+
+// Implemented by connections that are notified
+// when the client's terminal is resized.
+type ResizableConn interface {
+	Conn
+
+	// Sets the function called with the new
+	// size after the terminal is resized.
+	OnResize(func(width uint16, height uint16))
+}
+
+// A plain TCP connection, which carries
+// no information about the terminal.
+type netConn struct {
+	net.Conn
+}
+
+func (c netConn) Terminal() Terminal {
+	return Terminal{}
+}
@@ -0,0 +1,446 @@
+package nui
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Returns the format of a widget, which is
+// reversed while the widget has focus.
+func focusFormat(format Format, focused bool) Format {
+	if focused {
+		format.Reverse = !format.Reverse
+	}
+	return format
+}
+
+// Represents a bar that fills up from the left as Value goes
+// from 0 to Max, with optional text in the middle, such as
+// the time that is left.
+type ProgressBar struct {
+	X     uint16
+	Y     uint16
+	Width uint16
+	Text  string
+	Value int
+	Max   int
+
+	// Formats of the filled and the empty part
+	Format      Format
+	EmptyFormat Format
+}
+
+func (p *ProgressBar) Draw(buf *Buffer) {
+	filled := 0
+	if p.Max > 0 {
+		filled = int(p.Width) * p.Value / p.Max
+	}
+	if filled < 0 {
+		filled = 0
+	}
+	if filled > int(p.Width) {
+		filled = int(p.Width)
+	}
+
+	start := (int(p.Width) - len(p.Text)) / 2
+	for i := 0; i < int(p.Width); i++ {
+		ch := byte(' ')
+		if i >= start && i-start < len(p.Text) {
+			ch = p.Text[i-start]
+		}
+		buf.SetCell(int(p.X)+i, int(p.Y), ch, ternaryFormat(i < filled, p.Format, p.EmptyFormat))
+	}
+}
+
+func (p *ProgressBar) Size() (uint16, uint16) {
+	return p.Width, 1
+}
+
+func (p *ProgressBar) Place(area Rect) {
+	p.X, p.Y = area.X, area.Y
+}
+
+// Represents a box that is checked and unchecked
+// with space, enter or a click.
+//
+// Note: When the event handlers are called,
+// the screen that this widget belongs to is write-locked.
+type Checkbox struct {
+	X       uint16
+	Y       uint16
+	Format  Format
+	Text    string
+	Checked bool
+
+	HandleChange func(checked bool)
+
+	focused bool
+}
+
+func (c *Checkbox) Draw(buf *Buffer) {
+	format := focusFormat(c.Format, c.focused)
+	buf.WriteString(int(c.X), int(c.Y), "[ ] "+c.Text, c.Format)
+	buf.WriteString(int(c.X), int(c.Y), "[ ]", format)
+	if c.Checked {
+		buf.SetCell(int(c.X)+1, int(c.Y), 'x', format)
+	}
+	buf.SetCursor(int(c.X)+1, int(c.Y), format)
+}
+
+func (c *Checkbox) Size() (uint16, uint16) {
+	return uint16(len(c.Text)) + 4, 1
+}
+
+func (c *Checkbox) Place(area Rect) {
+	c.X, c.Y = area.X, area.Y
+}
+
+func (c *Checkbox) Focus(focus bool) {
+	c.focused = focus
+}
+
+func (c *Checkbox) Keypress(ch byte) {
+	if ch == ' ' || ch == '\n' {
+		c.toggle()
+	}
+}
+
+func (c *Checkbox) Mouse(ev MouseEvent) bool {
+	w, h := c.Size()
+	if ev.Button != MouseLeft || ev.Release || !(Rect{c.X, c.Y, w, h}).Contains(int(ev.X), int(ev.Y)) {
+		return false
+	}
+	c.toggle()
+	return true
+}
+
+func (c *Checkbox) toggle() {
+	c.Checked = !c.Checked
+	if c.HandleChange != nil {
+		c.HandleChange(c.Checked)
+	}
+}
+
+// Represents a list of options, one per line, of which exactly
+// one is selected. The arrow keys or a click select an option.
+//
+// Note: When the event handlers are called,
+// the screen that this widget belongs to is write-locked.
+type RadioGroup struct {
+	X        uint16
+	Y        uint16
+	Format   Format
+	Options  []string
+	Selected int
+
+	HandleChange func(idx int)
+
+	focused bool
+}
+
+func (r *RadioGroup) Draw(buf *Buffer) {
+	for i, option := range r.Options {
+		y := int(r.Y) + i
+		mark := "( ) "
+		if i == r.Selected {
+			mark = "(*) "
+		}
+		buf.WriteString(int(r.X), y, mark+option, r.Format)
+		if i == r.Selected {
+			buf.WriteString(int(r.X), y, mark[:3], focusFormat(r.Format, r.focused))
+		}
+	}
+	buf.SetCursor(int(r.X)+1, int(r.Y)+r.Selected, focusFormat(r.Format, r.focused))
+}
+
+func (r *RadioGroup) Size() (uint16, uint16) {
+	var width uint16
+	for _, option := range r.Options {
+		if w := uint16(len(option)) + 4; w > width {
+			width = w
+		}
+	}
+	return width, uint16(len(r.Options))
+}
+
+func (r *RadioGroup) Place(area Rect) {
+	r.X, r.Y = area.X, area.Y
+}
+
+func (r *RadioGroup) Focus(focus bool) {
+	r.focused = focus
+}
+
+func (r *RadioGroup) Keypress(ch byte) {}
+
+func (r *RadioGroup) Key(key Key) {
+	if key == KeyUp || key == KeyLeft {
+		r.selectOption(r.Selected - 1)
+	} else if key == KeyDown || key == KeyRight {
+		r.selectOption(r.Selected + 1)
+	}
+}
+
+func (r *RadioGroup) Mouse(ev MouseEvent) bool {
+	w, h := r.Size()
+	if ev.Button != MouseLeft || ev.Release || !(Rect{r.X, r.Y, w, h}).Contains(int(ev.X), int(ev.Y)) {
+		return false
+	}
+	r.selectOption(int(ev.Y - r.Y))
+	return true
+}
+
+func (r *RadioGroup) selectOption(idx int) {
+	if idx < 0 || idx >= len(r.Options) || idx == r.Selected {
+		return
+	}
+	r.Selected = idx
+	if r.HandleChange != nil {
+		r.HandleChange(idx)
+	}
+}
+
+// Represents a number between Min and Max, shown as a handle
+// on a track followed by the value. The left and right arrow
+// keys, '-' and '+' change the value by Step, Home and End
+// move it to Min and Max, and clicking the track moves it there.
+//
+// Note: When the event handlers are called,
+// the screen that this widget belongs to is write-locked.
+type Slider struct {
+	X uint16
+	Y uint16
+	// Width of the track
+	Width  uint16
+	Format Format
+
+	Value float64
+	Min   float64
+	Max   float64
+	Step  float64
+
+	HandleChange func(value float64)
+
+	focused bool
+}
+
+// Returns the position of the handle on the track.
+func (s *Slider) handle() int {
+	if s.Max <= s.Min || s.Width < 2 {
+		return 0
+	}
+	pos := int(math.Round((s.Value - s.Min) / (s.Max - s.Min) * float64(s.Width-1)))
+	if pos < 0 {
+		return 0
+	}
+	if pos >= int(s.Width) {
+		return int(s.Width) - 1
+	}
+	return pos
+}
+
+func (s *Slider) Draw(buf *Buffer) {
+	format := focusFormat(s.Format, s.focused)
+	handle := s.handle()
+	for i := 0; i < int(s.Width); i++ {
+		if i == handle {
+			buf.SetCell(int(s.X)+i, int(s.Y), 'O', format)
+		} else {
+			buf.SetCell(int(s.X)+i, int(s.Y), '-', s.Format)
+		}
+	}
+	buf.WriteString(int(s.X+s.Width)+1, int(s.Y), strconv.FormatFloat(s.Value, 'f', s.decimals(), 64), s.Format)
+	buf.SetCursor(int(s.X)+handle, int(s.Y), format)
+}
+
+// Returns the number of decimal places of Step, which
+// the value is shown with.
+func (s *Slider) decimals() int {
+	step := strconv.FormatFloat(s.Step, 'f', -1, 64)
+	if dot := strings.IndexByte(step, '.'); dot >= 0 {
+		return len(step) - dot - 1
+	}
+	return 0
+}
+
+// Includes the value, as wide as the wider of Min and
+// Max with as many decimal places as Step.
+func (s *Slider) Size() (uint16, uint16) {
+	value := len(strconv.FormatFloat(s.Min, 'f', 0, 64))
+	if n := len(strconv.FormatFloat(s.Max, 'f', 0, 64)); n > value {
+		value = n
+	}
+	if decimals := s.decimals(); decimals > 0 {
+		value += 1 + decimals
+	}
+	return s.Width + 1 + uint16(value), 1
+}
+
+func (s *Slider) Place(area Rect) {
+	s.X, s.Y = area.X, area.Y
+}
+
+func (s *Slider) Focus(focus bool) {
+	s.focused = focus
+}
+
+func (s *Slider) Keypress(ch byte) {
+	if ch == '-' {
+		s.setValue(s.Value - s.Step)
+	} else if ch == '+' || ch == '=' {
+		s.setValue(s.Value + s.Step)
+	}
+}
+
+func (s *Slider) Key(key Key) {
+	if key == KeyLeft {
+		s.setValue(s.Value - s.Step)
+	} else if key == KeyRight {
+		s.setValue(s.Value + s.Step)
+	} else if key == KeyHome {
+		s.setValue(s.Min)
+	} else if key == KeyEnd {
+		s.setValue(s.Max)
+	}
+}
+
+func (s *Slider) Mouse(ev MouseEvent) bool {
+	if ev.Release || !(Rect{s.X, s.Y, s.Width, 1}).Contains(int(ev.X), int(ev.Y)) {
+		return false
+	}
+
+	if ev.Button == MouseLeft && s.Width > 1 {
+		s.setValue(s.Min + float64(ev.X-s.X)/float64(s.Width-1)*(s.Max-s.Min))
+	} else if ev.Button == MouseWheelUp {
+		s.setValue(s.Value + s.Step)
+	} else if ev.Button == MouseWheelDown {
+		s.setValue(s.Value - s.Step)
+	}
+	return true
+}
+
+// Sets the value, rounded to a multiple of
+// Step from Min and kept between Min and Max.
+func (s *Slider) setValue(value float64) {
+	if s.Step > 0 {
+		value = s.Min + math.Round((value-s.Min)/s.Step)*s.Step
+	}
+	value = math.Max(s.Min, math.Min(s.Max, value))
+	if value == s.Value {
+		return
+	}
+
+	s.Value = value
+	if s.HandleChange != nil {
+		s.HandleChange(value)
+	}
+}
+
+// Represents an integer between Min and Max, shown between arrows,
+// such as "< 2 >". The arrow keys, '-', '+' and the mouse wheel
+// change it by Step, and clicking an arrow changes it in its direction.
+//
+// Note: When the event handlers are called,
+// the screen that this widget belongs to is write-locked.
+type Spinner struct {
+	X      uint16
+	Y      uint16
+	Format Format
+
+	Value int
+	Min   int
+	Max   int
+	Step  int
+
+	HandleChange func(value int)
+
+	focused bool
+}
+
+// Width of the widest value
+func (s *Spinner) valueWidth() int {
+	width := len(strconv.Itoa(s.Min))
+	if n := len(strconv.Itoa(s.Max)); n > width {
+		width = n
+	}
+	return width
+}
+
+func (s *Spinner) Draw(buf *Buffer) {
+	format := focusFormat(s.Format, s.focused)
+	text := fmt.Sprintf("< %*d >", s.valueWidth(), s.Value)
+	buf.WriteString(int(s.X), int(s.Y), text, format)
+	buf.SetCursor(int(s.X)+len(text)-2, int(s.Y), format)
+}
+
+func (s *Spinner) Size() (uint16, uint16) {
+	return uint16(s.valueWidth()) + 4, 1
+}
+
+func (s *Spinner) Place(area Rect) {
+	s.X, s.Y = area.X, area.Y
+}
+
+func (s *Spinner) Focus(focus bool) {
+	s.focused = focus
+}
+
+func (s *Spinner) Keypress(ch byte) {
+	if ch == '-' {
+		s.setValue(s.Value - s.step())
+	} else if ch == '+' || ch == '=' {
+		s.setValue(s.Value + s.step())
+	}
+}
+
+func (s *Spinner) Key(key Key) {
+	if key == KeyLeft || key == KeyDown {
+		s.setValue(s.Value - s.step())
+	} else if key == KeyRight || key == KeyUp {
+		s.setValue(s.Value + s.step())
+	} else if key == KeyHome {
+		s.setValue(s.Min)
+	} else if key == KeyEnd {
+		s.setValue(s.Max)
+	}
+}
+
+func (s *Spinner) Mouse(ev MouseEvent) bool {
+	w, h := s.Size()
+	if ev.Release || !(Rect{s.X, s.Y, w, h}).Contains(int(ev.X), int(ev.Y)) {
+		return false
+	}
+
+	if ev.Button == MouseWheelUp || ev.Button == MouseLeft && ev.X >= s.X+w-2 {
+		s.setValue(s.Value + s.step())
+	} else if ev.Button == MouseWheelDown || ev.Button == MouseLeft && ev.X < s.X+2 {
+		s.setValue(s.Value - s.step())
+	}
+	return true
+}
+
+func (s *Spinner) step() int {
+	if s.Step < 1 {
+		return 1
+	}
+	return s.Step
+}
+
+func (s *Spinner) setValue(value int) {
+	if value < s.Min {
+		value = s.Min
+	}
+	if value > s.Max {
+		value = s.Max
+	}
+	if value == s.Value {
+		return
+	}
+
+	s.Value = value
+	if s.HandleChange != nil {
+		s.HandleChange(value)
+	}
+}
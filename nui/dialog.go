@@ -0,0 +1,115 @@
+package nui
+
+// Widest line of a dialog's message
+const dialogWidth = 48
+
+var (
+	dialogFormat = Format{Fg: LightWhite, Bg: Blue}
+	buttonFormat = Format{Fg: LightWhite, Bg: LightBlack}
+	entryFormat  = Format{Fg: Black, Bg: LightWhite}
+)
+
+// The root widget of a dialog. Escape cancels it.
+type dialog struct {
+	Center
+	handleCancel func()
+}
+
+func (d *dialog) Key(key Key) {
+	if key == KeyEscape {
+		d.handleCancel()
+		return
+	}
+	d.Center.Key(key)
+}
+
+// Returns a dialog with a title, a message, optional widgets below
+// the message and a row of buttons, to be pushed for a client. The
+// dialog is removed before close is called with the index of the
+// pressed button, or -1 if escape was pressed; close is called at
+// most once. The returned function closes the dialog in the same way.
+func (s *Server) newDialog(clientID int, title string, message string, body []Widget, buttons []string, close func(button int)) (*Screen, func(button int)) {
+	screen := &Screen{}
+	closed := false
+	done := func(button int) {
+		if closed {
+			return
+		}
+		closed = true
+		s.RemoveScreen(clientID, screen)
+		close(button)
+	}
+
+	var children []Widget
+	for _, line := range wrap(message, dialogWidth) {
+		children = append(children, &Label{Format: dialogFormat, Text: line})
+	}
+	children = append(children, body...)
+
+	row := &HBox{Spacing: 2}
+	for i, text := range buttons {
+		i := i
+		row.Children = append(row.Children, &Button{
+			Format:      buttonFormat,
+			Text:        text,
+			HandleClick: func() { done(i) },
+		})
+	}
+
+	root := &dialog{
+		Center: Center{Child: &Frame{
+			Title:  title,
+			Format: dialogFormat,
+			Child: &Padding{Top: 1, Right: 2, Bottom: 1, Left: 2, Child: &VBox{
+				Children: append(children, &Padding{Top: 1, Child: row}),
+			}},
+		}},
+		handleCancel: func() { done(-1) },
+	}
+	root.Focus(true)
+
+	screen.Widgets = []Widget{root}
+	return screen, done
+}
+
+// Shows a message over the current screen of a particular client ID
+// until it is dismissed. handleClose is called afterwards if it is set.
+//
+// Note: When the event handlers are called,
+// the dialog's screen is write-locked.
+func (s *Server) Alert(clientID int, title string, message string, handleClose func()) {
+	screen, _ := s.newDialog(clientID, title, message, nil, []string{"OK"}, func(button int) {
+		if handleClose != nil {
+			handleClose()
+		}
+	})
+	s.PushScreen(clientID, screen)
+}
+
+// Asks a yes or no question over the current screen of a particular
+// client ID. handleResult is called with true if the user confirms,
+// or false if they cancel.
+//
+// Note: When the event handlers are called,
+// the dialog's screen is write-locked.
+func (s *Server) Confirm(clientID int, title string, message string, handleResult func(ok bool)) {
+	screen, _ := s.newDialog(clientID, title, message, nil, []string{"OK", "Cancel"}, func(button int) {
+		handleResult(button == 0)
+	})
+	s.PushScreen(clientID, screen)
+}
+
+// Asks for a line of at most max characters over the current screen
+// of a particular client ID. handleResult is called with the text
+// and true if the user submits it, or false if they cancel.
+//
+// Note: When the event handlers are called,
+// the dialog's screen is write-locked.
+func (s *Server) Prompt(clientID int, title string, message string, max int, handleResult func(text string, ok bool)) {
+	entry := &Entry{Format: entryFormat, Max: max}
+	screen, done := s.newDialog(clientID, title, message, []Widget{entry}, []string{"OK", "Cancel"}, func(button int) {
+		handleResult(entry.Text, button == 0)
+	})
+	entry.HandleEnter = func(text string) { done(0) }
+	s.PushScreen(clientID, screen)
+}
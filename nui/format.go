@@ -3,9 +3,13 @@ package nui
 import (
 	"fmt"
 	"io"
+	"strings"
 )
 
-type Color int8
+// Type Color represents a terminal color. The named
+// constants are the 16 basic ANSI colors; use Indexed
+// and RGB for 256-color and 24-bit colors.
+type Color int32
 
 const (
 	Black   Color = 0
@@ -28,24 +32,274 @@ const (
 	LightWhite   Color = 67
 )
 
+const (
+	colorIndexed Color = 1 << 8
+	colorRGB     Color = 1 << 24
+)
+
+// Returns a color from the 256-color palette.
+func Indexed(n uint8) Color {
+	return colorIndexed | Color(n)
+}
+
+// Returns a 24-bit color.
+func RGB(r, g, b uint8) Color {
+	return colorRGB | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
+
+var colorNames = map[Color]string{
+	Black: "Black", Red: "Red", Green: "Green", Yellow: "Yellow",
+	Blue: "Blue", Magenta: "Magenta", Cyan: "Cyan", White: "White", Default: "Default",
+	LightBlack: "LightBlack", LightRed: "LightRed", LightGreen: "LightGreen", LightYellow: "LightYellow",
+	LightBlue: "LightBlue", LightMagenta: "LightMagenta", LightCyan: "LightCyan", LightWhite: "LightWhite",
+}
+
+// Returns the name of a basic color, "Indexed(n)"
+// for a 256-color one, or "#rrggbb" for a 24-bit one.
+func (c Color) String() string {
+	if c&colorRGB != 0 {
+		return fmt.Sprintf("#%06x", int32(c&^colorRGB))
+	}
+	if c&colorIndexed != 0 {
+		return fmt.Sprintf("Indexed(%d)", uint8(c))
+	}
+	if name, ok := colorNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Color(%d)", int32(c))
+}
+
+// Type ColorMode describes which colors
+// a terminal is able to display.
+type ColorMode uint8
+
+const (
+	Colors16 ColorMode = iota
+	Colors256
+	TrueColor
+)
+
+func (m ColorMode) String() string {
+	switch m {
+	case Colors256:
+		return "256"
+	case TrueColor:
+		return "True"
+	default:
+		return "16"
+	}
+}
+
+// xterm's default values for the 16 basic colors,
+// indexed in the same order as the 256-color palette.
+var basicPalette = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+func (c Color) rgb() (uint8, uint8, uint8) {
+	if c&colorRGB != 0 {
+		return uint8(c >> 16), uint8(c >> 8), uint8(c)
+	}
+
+	n := int(uint8(c))
+	if c&colorIndexed == 0 {
+		n = c.basicIndex()
+	}
+	switch {
+	case n < 16:
+		return basicPalette[n][0], basicPalette[n][1], basicPalette[n][2]
+	case n < 232:
+		n -= 16
+		return cubeLevels[n/36], cubeLevels[n/6%6], cubeLevels[n%6]
+	default:
+		v := uint8(8 + 10*(n-232))
+		return v, v, v
+	}
+}
+
+// Index of a basic color in the 256-color palette.
+func (c Color) basicIndex() int {
+	if c >= LightBlack {
+		return int(c-LightBlack) + 8
+	}
+	return int(c)
+}
+
+func distance(r1, g1, b1, r2, g2, b2 uint8) int {
+	dr := int(r1) - int(r2)
+	dg := int(g1) - int(g2)
+	db := int(b1) - int(b2)
+	return dr*dr + dg*dg + db*db
+}
+
+// Returns the closest color that can be displayed
+// with the given color mode.
+func (c Color) Downgrade(mode ColorMode) Color {
+	if c&(colorRGB|colorIndexed) == 0 || mode == TrueColor {
+		return c
+	}
+	if c&colorIndexed != 0 && mode == Colors256 {
+		return c
+	}
+	if c&colorIndexed != 0 && uint8(c) < 16 {
+		n := uint8(c)
+		if n >= 8 {
+			return LightBlack + Color(n-8)
+		}
+		return Color(n)
+	}
+
+	r, g, b := c.rgb()
+	if mode == Colors256 {
+		cube := 16 + 36*nearestLevel(r) + 6*nearestLevel(g) + nearestLevel(b)
+		gray := 232 + ((int(r)+int(g)+int(b))/3-3)/10
+		if gray < 232 {
+			gray = 232
+		} else if gray > 255 {
+			gray = 255
+		}
+		cr, cg, cb := Indexed(uint8(cube)).rgb()
+		gr, gg, gb := Indexed(uint8(gray)).rgb()
+		if distance(r, g, b, gr, gg, gb) < distance(r, g, b, cr, cg, cb) {
+			return Indexed(uint8(gray))
+		}
+		return Indexed(uint8(cube))
+	}
+
+	best := 0
+	for i, p := range basicPalette {
+		if distance(r, g, b, p[0], p[1], p[2]) < distance(r, g, b, basicPalette[best][0], basicPalette[best][1], basicPalette[best][2]) {
+			best = i
+		}
+	}
+	if best >= 8 {
+		return LightBlack + Color(best-8)
+	}
+	return Color(best)
+}
+
+func nearestLevel(v uint8) int {
+	best := 0
+	for i, l := range cubeLevels {
+		if distance(v, 0, 0, l, 0, 0) < distance(v, 0, 0, cubeLevels[best], 0, 0) {
+			best = i
+		}
+	}
+	return best
+}
+
+// Writes the SGR parameters selecting this color,
+// where base is 30 for foreground and 40 for background.
+func (c Color) sgr(base int) string {
+	switch {
+	case c&colorRGB != 0:
+		return fmt.Sprintf("%d;2;%d;%d;%d", base+8, uint8(c>>16), uint8(c>>8), uint8(c))
+	case c&colorIndexed != 0:
+		return fmt.Sprintf("%d;5;%d", base+8, uint8(c))
+	default:
+		return fmt.Sprint(int(c) + base)
+	}
+}
+
 type Format struct {
 	Fg        Color
 	Bg        Color
 	Bold      bool
+	Dim       bool
+	Italic    bool
 	Underline bool
+	Reverse   bool
 }
 
-func (f Format) Apply(w io.Writer) {
+// Returns the colors and attributes of the
+// format, such as "LightRed on Black, bold".
+func (f Format) String() string {
+	s := f.Fg.String() + " on " + f.Bg.String()
+	for _, attr := range []struct {
+		set  bool
+		name string
+	}{{f.Bold, "bold"}, {f.Dim, "dim"}, {f.Italic, "italic"}, {f.Underline, "underline"}, {f.Reverse, "reverse"}} {
+		if attr.set {
+			s += ", " + attr.name
+		}
+	}
+	return s
+}
+
+// Writes the escape codes for this format, with colors
+// downgraded to what the given color mode supports.
+func (f Format) Apply(w io.Writer, mode ColorMode) {
+	fmt.Fprint(w, "\x1b[22m")
 	if f.Bold {
 		fmt.Fprint(w, "\x1b[1m")
+	}
+	if f.Dim {
+		fmt.Fprint(w, "\x1b[2m")
+	}
+	if f.Italic {
+		fmt.Fprint(w, "\x1b[3m")
 	} else {
-		fmt.Fprint(w, "\x1b[22m")
+		fmt.Fprint(w, "\x1b[23m")
 	}
 	if f.Underline {
 		fmt.Fprint(w, "\x1b[4m")
 	} else {
 		fmt.Fprint(w, "\x1b[24m")
 	}
-	fmt.Fprintf(w, "\x1b[%dm", int(f.Fg)+30)
-	fmt.Fprintf(w, "\x1b[%dm", int(f.Bg)+40)
+	if f.Reverse {
+		fmt.Fprint(w, "\x1b[7m")
+	} else {
+		fmt.Fprint(w, "\x1b[27m")
+	}
+	fmt.Fprintf(w, "\x1b[%sm", f.Fg.Downgrade(mode).sgr(30))
+	fmt.Fprintf(w, "\x1b[%sm", f.Bg.Downgrade(mode).sgr(40))
+}
+
+// Writes a single escape code changing the
+// terminal's format from prev to f, or nothing
+// if the two formats look the same.
+func (f Format) applyFrom(w io.Writer, prev Format, mode ColorMode) {
+	var params []string
+	if (prev.Bold && !f.Bold) || (prev.Dim && !f.Dim) {
+		params = append(params, "22")
+		prev.Bold, prev.Dim = false, false
+	}
+	if f.Bold && !prev.Bold {
+		params = append(params, "1")
+	}
+	if f.Dim && !prev.Dim {
+		params = append(params, "2")
+	}
+	if f.Italic != prev.Italic {
+		params = append(params, ternaryString(f.Italic, "3", "23"))
+	}
+	if f.Underline != prev.Underline {
+		params = append(params, ternaryString(f.Underline, "4", "24"))
+	}
+	if f.Reverse != prev.Reverse {
+		params = append(params, ternaryString(f.Reverse, "7", "27"))
+	}
+	if fg := f.Fg.Downgrade(mode); fg != prev.Fg.Downgrade(mode) {
+		params = append(params, fg.sgr(30))
+	}
+	if bg := f.Bg.Downgrade(mode); bg != prev.Bg.Downgrade(mode) {
+		params = append(params, bg.sgr(40))
+	}
+
+	if len(params) != 0 {
+		fmt.Fprintf(w, "\x1b[%sm", strings.Join(params, ";"))
+	}
+}
+
+func ternaryString(cond bool, t string, f string) string {
+	if cond {
+		return t
+	} else {
+		return f
+	}
 }
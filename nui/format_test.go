@@ -0,0 +1,63 @@
+package nui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDowngrade(t *testing.T) {
+	tests := []struct {
+		color     Color
+		colors16  Color
+		colors256 Color
+	}{
+		{Red, Red, Red},
+		{LightWhite, LightWhite, LightWhite},
+		{Default, Default, Default},
+		{RGB(255, 0, 0), LightRed, Indexed(196)},
+		{RGB(0, 0, 255), Blue, Indexed(21)},
+		{RGB(0, 0, 0), Black, Indexed(16)},
+		{RGB(128, 128, 128), LightBlack, Indexed(244)},
+		{Indexed(1), Red, Indexed(1)},
+		{Indexed(9), LightRed, Indexed(9)},
+		{Indexed(196), LightRed, Indexed(196)},
+		{Indexed(232), Black, Indexed(232)},
+	}
+	for _, test := range tests {
+		if got := test.color.Downgrade(Colors16); got != test.colors16 {
+			t.Errorf("%v with 16 colors is %v, want %v", test.color, got, test.colors16)
+		}
+		if got := test.color.Downgrade(Colors256); got != test.colors256 {
+			t.Errorf("%v with 256 colors is %v, want %v", test.color, got, test.colors256)
+		}
+		if got := test.color.Downgrade(TrueColor); got != test.color {
+			t.Errorf("%v with true color is %v", test.color, got)
+		}
+	}
+}
+
+func TestFormatApply(t *testing.T) {
+	tests := []struct {
+		format Format
+		mode   ColorMode
+		want   string
+	}{
+		{Format{Fg: White, Bg: Black}, Colors16,
+			"\x1b[22m\x1b[23m\x1b[24m\x1b[27m\x1b[37m\x1b[40m"},
+		{Format{Fg: LightRed, Bg: Default, Bold: true, Dim: true, Italic: true, Underline: true, Reverse: true}, TrueColor,
+			"\x1b[22m\x1b[1m\x1b[2m\x1b[3m\x1b[4m\x1b[7m\x1b[91m\x1b[49m"},
+		{Format{Fg: RGB(255, 0, 0), Bg: Indexed(21), Bold: true}, TrueColor,
+			"\x1b[22m\x1b[1m\x1b[23m\x1b[24m\x1b[27m\x1b[38;2;255;0;0m\x1b[48;5;21m"},
+		{Format{Fg: RGB(255, 0, 0), Bg: Indexed(21), Bold: true}, Colors256,
+			"\x1b[22m\x1b[1m\x1b[23m\x1b[24m\x1b[27m\x1b[38;5;196m\x1b[48;5;21m"},
+		{Format{Fg: RGB(255, 0, 0), Bg: Indexed(21), Bold: true}, Colors16,
+			"\x1b[22m\x1b[1m\x1b[23m\x1b[24m\x1b[27m\x1b[91m\x1b[44m"},
+	}
+	for _, test := range tests {
+		out := new(strings.Builder)
+		test.format.Apply(out, test.mode)
+		if out.String() != test.want {
+			t.Errorf("%v with %s colors: got %q, want %q", test.format, test.mode, out.String(), test.want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package nui
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Type Key represents a key that is sent
+// as an escape sequence rather than a character.
+type Key int
+
+const (
+	KeyEscape Key = iota + 1
+	KeyUp
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyHome
+	KeyEnd
+	KeyPageUp
+	KeyPageDown
+	KeyInsert
+	KeyDelete
+	KeyBacktab
+)
+
+// Represents a focusable widget that handles keys
+// sent as escape sequences, such as the arrow keys.
+type KeyWidget interface {
+	FocusableWidget
+
+	// Called when a key is pressed.
+	Key(key Key)
+}
+
+type MouseButton int
+
+const (
+	MouseLeft MouseButton = iota
+	MouseMiddle
+	MouseRight
+	MouseWheelUp
+	MouseWheelDown
+)
+
+// Type MouseEvent represents a mouse button being pressed
+// or released, or the wheel being scrolled, at a cell.
+type MouseEvent struct {
+	X, Y    uint16
+	Button  MouseButton
+	Release bool
+}
+
+// Represents a widget that handles mouse events.
+// When Mouse is called, the screen belonging
+// to the widget is write-locked.
+type MouseWidget interface {
+	Widget
+
+	// Called for every mouse event, including ones outside of
+	// the widget. Returns true if the widget handled the event,
+	// in which case it is not given to any other widget.
+	Mouse(ev MouseEvent) bool
+}
+
+// Starts reporting mouse buttons and the wheel with SGR coordinates.
+const enableMouse = "\x1b[?1000h\x1b[?1006h"
+
+// Longest escape sequence that is parsed.
+const maxEscape = 32
+
+// A single character, key or mouse event
+// read from a client. Only one field is set.
+type input struct {
+	ch    byte
+	key   Key
+	mouse *MouseEvent
+}
+
+var csiKeys = map[byte]Key{
+	'A': KeyUp, 'B': KeyDown, 'C': KeyRight, 'D': KeyLeft,
+	'H': KeyHome, 'F': KeyEnd, 'Z': KeyBacktab,
+}
+
+var tildeKeys = map[int]Key{
+	1: KeyHome, 2: KeyInsert, 3: KeyDelete, 4: KeyEnd,
+	5: KeyPageUp, 6: KeyPageDown, 7: KeyHome, 8: KeyEnd,
+}
+
+// Splits what a client sends into inputs. Escape sequences that
+// are cut off at the end of a read are kept until the next one.
+type inputParser struct {
+	pending []byte
+}
+
+func (p *inputParser) parse(data []byte) []input {
+	data = append(p.pending, data...)
+	p.pending = nil
+
+	var inputs []input
+	for len(data) > 0 {
+		if data[0] != '\x1b' {
+			inputs = append(inputs, input{ch: data[0]})
+			data = data[1:]
+			continue
+		}
+
+		n, in, complete := parseEscape(data)
+		if !complete {
+			p.pending = append([]byte(nil), data...)
+			break
+		}
+		if in != (input{}) {
+			// Unknown sequences, such as the ones that
+			// start and end a paste, are left out.
+			inputs = append(inputs, in)
+		}
+		data = data[n:]
+	}
+	return inputs
+}
+
+// Parses an escape sequence at the start of data. Returns
+// its length and the input, or false if data ends before
+// the sequence does. A lone escape is the escape key.
+func parseEscape(data []byte) (int, input, bool) {
+	if len(data) == 1 {
+		return 1, input{key: KeyEscape}, true
+	}
+
+	if data[1] == 'O' {
+		// SS3, sent for arrow keys in application mode
+		if len(data) < 3 {
+			return 0, input{}, false
+		}
+		return 3, input{key: csiKeys[data[2]]}, true
+	}
+	if data[1] != '[' {
+		return 1, input{key: KeyEscape}, true
+	}
+
+	end := 2
+	for end < len(data) && (data[end] < 0x40 || data[end] > 0x7e) {
+		if data[end] < 0x20 || end >= maxEscape {
+			// Not a valid sequence, so it is ignored
+			return end, input{}, true
+		}
+		end++
+	}
+	if end == len(data) {
+		return 0, input{}, false
+	}
+
+	params, final := string(data[2:end]), data[end]
+	if strings.HasPrefix(params, "<") && (final == 'M' || final == 'm') {
+		return end + 1, input{mouse: parseMouse(params[1:], final == 'm')}, true
+	}
+	if final == '~' {
+		n, _ := strconv.Atoi(strings.Split(params, ";")[0])
+		return end + 1, input{key: tildeKeys[n]}, true
+	}
+	return end + 1, input{key: csiKeys[final]}, true
+}
+
+// Parses the parameters of an SGR mouse report.
+// Returns nil for events that are not reported to widgets.
+func parseMouse(params string, release bool) *MouseEvent {
+	fields := strings.Split(params, ";")
+	if len(fields) != 3 {
+		return nil
+	}
+	b, err1 := strconv.Atoi(fields[0])
+	x, err2 := strconv.Atoi(fields[1])
+	y, err3 := strconv.Atoi(fields[2])
+	if err1 != nil || err2 != nil || err3 != nil || x < 1 || y < 1 || b&32 != 0 {
+		return nil
+	}
+
+	ev := &MouseEvent{X: uint16(x - 1), Y: uint16(y - 1), Release: release}
+	if b&64 != 0 {
+		ev.Button = ternaryButton(b&1 == 0, MouseWheelUp, MouseWheelDown)
+	} else if b&3 == 3 {
+		return nil
+	} else {
+		ev.Button = MouseButton(b & 3)
+	}
+	return ev
+}
+
+func ternaryButton(cond bool, a MouseButton, b MouseButton) MouseButton {
+	if cond {
+		return a
+	}
+	return b
+}
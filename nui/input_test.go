@@ -0,0 +1,89 @@
+package nui
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func chars(s string) []input {
+	var inputs []input
+	for i := 0; i < len(s); i++ {
+		inputs = append(inputs, input{ch: s[i]})
+	}
+	return inputs
+}
+
+func TestInputParser(t *testing.T) {
+	tests := []struct {
+		name  string
+		reads []string
+		want  []input
+	}{
+		{"characters", []string{"ab\r\t"}, chars("ab\r\t")},
+		{"escape", []string{"\x1b"}, []input{{key: KeyEscape}}},
+		{"escape before character", []string{"\x1bx"}, append([]input{{key: KeyEscape}}, chars("x")...)},
+		{"escapes", []string{"\x1b\x1b"}, []input{{key: KeyEscape}, {key: KeyEscape}}},
+		{"arrows", []string{"\x1b[A\x1b[B\x1b[C\x1b[D"}, []input{{key: KeyUp}, {key: KeyDown}, {key: KeyRight}, {key: KeyLeft}}},
+		{"application arrows", []string{"\x1bOA\x1bOD"}, []input{{key: KeyUp}, {key: KeyLeft}}},
+		{"home and end", []string{"\x1b[H\x1b[F\x1b[1~\x1b[4~\x1b[7~\x1b[8~"}, []input{
+			{key: KeyHome}, {key: KeyEnd}, {key: KeyHome}, {key: KeyEnd}, {key: KeyHome}, {key: KeyEnd},
+		}},
+		{"tilde keys", []string{"\x1b[2~\x1b[3~\x1b[5~\x1b[6~"}, []input{
+			{key: KeyInsert}, {key: KeyDelete}, {key: KeyPageUp}, {key: KeyPageDown},
+		}},
+		{"modifiers", []string{"\x1b[1;5A\x1b[3;2~"}, []input{{key: KeyUp}, {key: KeyDelete}}},
+		{"backtab", []string{"\x1b[Z"}, []input{{key: KeyBacktab}}},
+		{"unknown keys", []string{"a\x1b[99~\x1b[Qb\x1bO5"}, chars("ab")},
+
+		{"mouse press", []string{"\x1b[<0;1;1M"}, []input{{mouse: &MouseEvent{X: 0, Y: 0, Button: MouseLeft}}}},
+		{"mouse release", []string{"\x1b[<2;80;24m"}, []input{{mouse: &MouseEvent{X: 79, Y: 23, Button: MouseRight, Release: true}}}},
+		{"middle button", []string{"\x1b[<1;3;4M"}, []input{{mouse: &MouseEvent{X: 2, Y: 3, Button: MouseMiddle}}}},
+		{"wheel", []string{"\x1b[<64;5;6M\x1b[<65;5;6M"}, []input{
+			{mouse: &MouseEvent{X: 4, Y: 5, Button: MouseWheelUp}},
+			{mouse: &MouseEvent{X: 4, Y: 5, Button: MouseWheelDown}},
+		}},
+		{"mouse with modifiers", []string{"\x1b[<16;2;2M"}, []input{{mouse: &MouseEvent{X: 1, Y: 1, Button: MouseLeft}}}},
+		{"mouse motion", []string{"\x1b[<32;2;2M\x1b[<35;2;2M"}, nil},
+		{"no button", []string{"\x1b[<3;2;2m"}, nil},
+		{"invalid mouse", []string{"\x1b[<0;0;1M\x1b[<0;1M\x1b[<1.5;1;1M"}, nil},
+
+		{"paste", []string{"\x1b[200~hi\x1b[\x1b[201~"}, chars("hi")},
+		{"split escape", []string{"a\x1b[", "A"}, []input{{ch: 'a'}, {key: KeyUp}}},
+		{"split application arrow", []string{"\x1bO", "B"}, []input{{key: KeyDown}}},
+		{"split mouse", []string{"\x1b[<0;1", "0;2", "0M"}, []input{{mouse: &MouseEvent{X: 9, Y: 19, Button: MouseLeft}}}},
+		{"split paste", []string{"\x1b[20", "0~h", "i\x1b[201", "~"}, chars("hi")},
+		{"control character", []string{"\x1b[1\ra"}, chars("\ra")},
+		{"too long", []string{"\x1b[" + strings.Repeat("1", 40) + "~"}, chars(strings.Repeat("1", 10) + "~")},
+	}
+	for _, test := range tests {
+		p := new(inputParser)
+		var got []input
+		for _, read := range test.reads {
+			got = append(got, p.parse([]byte(read))...)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %s, want %s", test.name, formatInputs(got), formatInputs(test.want))
+		}
+		if len(p.pending) != 0 {
+			t.Errorf("%s: %q is left over", test.name, p.pending)
+		}
+	}
+}
+
+func formatInputs(inputs []input) string {
+	var s []string
+	for _, in := range inputs {
+		switch {
+		case in.mouse != nil:
+			s = append(s, strconv.Quote(fmt.Sprintf("%+v", *in.mouse)))
+		case in.key != 0:
+			s = append(s, fmt.Sprintf("key %d", in.key))
+		default:
+			s = append(s, strconv.QuoteRune(rune(in.ch)))
+		}
+	}
+	return "[" + strings.Join(s, ", ") + "]"
+}
@@ -0,0 +1,505 @@
+package nui
+
+// Type Rect represents a rectangular area of a buffer.
+type Rect struct {
+	X, Y          uint16
+	Width, Height uint16
+}
+
+// Represents a widget that can be sized and
+// positioned by a container.
+type LayoutWidget interface {
+	Widget
+
+	// Returns the space needed to show the whole widget.
+	Size() (width uint16, height uint16)
+
+	// Moves the widget into the given area. Widgets
+	// smaller than the area are placed in its top-left corner.
+	Place(area Rect)
+}
+
+// Represents a focusable widget that contains other
+// focusable widgets, such as a container. Tab moves focus
+// through its children before leaving it.
+type FocusGroup interface {
+	FocusableWidget
+
+	// Moves focus to the next focusable child, or to the first
+	// one if no child is focused. Returns false, leaving no child
+	// focused, if there are no more focusable children.
+	FocusNext() bool
+}
+
+func sizeOf(w Widget) (uint16, uint16) {
+	if w, ok := w.(LayoutWidget); ok {
+		return w.Size()
+	}
+	return 0, 0
+}
+
+func place(w Widget, area Rect) {
+	if w, ok := w.(LayoutWidget); ok {
+		w.Place(area)
+	}
+}
+
+func sub(a uint16, b uint16) uint16 {
+	if b > a {
+		return 0
+	}
+	return a - b
+}
+
+// State shared by all containers.
+type container struct {
+	area   Rect
+	placed bool
+
+	// Index of the focused child, plus one.
+	// Zero if no child is focused.
+	focus int
+
+	// Areas of the children when they were last drawn
+	areas []Rect
+}
+
+// Returns the area the container was placed in, or the whole
+// buffer if the container was not placed inside another container.
+func (c *container) bounds(buf *Buffer) Rect {
+	if c.placed {
+		return c.area
+	}
+	return buf.Bounds()
+}
+
+func (c *container) place(area Rect) {
+	c.area = area
+	c.placed = true
+}
+
+// Draws the children that were placed in areas, clipping
+// layout widgets to their area, and the focused child last.
+func (c *container) drawChildren(children []Widget, areas []Rect, buf *Buffer) {
+	c.areas = areas
+	draw := func(i int) {
+		if _, ok := children[i].(LayoutWidget); ok {
+			children[i].Draw(buf.Clip(areas[i]))
+		} else {
+			children[i].Draw(buf)
+		}
+	}
+
+	for i := range children {
+		if i+1 != c.focus {
+			draw(i)
+		}
+	}
+	if c.focus > 0 && c.focus <= len(children) {
+		draw(c.focus - 1)
+	}
+}
+
+func (c *container) focusNext(children []Widget) bool {
+	if c.focus > 0 && c.focus <= len(children) {
+		if group, ok := children[c.focus-1].(FocusGroup); ok && group.FocusNext() {
+			return true
+		}
+		if widget, ok := children[c.focus-1].(FocusableWidget); ok {
+			widget.Focus(false)
+		}
+	}
+
+	for i := c.focus; i < len(children); i++ {
+		widget, ok := children[i].(FocusableWidget)
+		if !ok {
+			continue
+		}
+		if group, ok := widget.(FocusGroup); ok && !group.FocusNext() {
+			continue
+		}
+
+		c.focus = i + 1
+		widget.Focus(true)
+		return true
+	}
+
+	c.focus = 0
+	return false
+}
+
+func (c *container) setFocus(children []Widget, focus bool) {
+	if focus {
+		if c.focus == 0 {
+			c.focusNext(children)
+		}
+		return
+	}
+
+	if c.focus > 0 && c.focus <= len(children) {
+		if widget, ok := children[c.focus-1].(FocusableWidget); ok {
+			widget.Focus(false)
+		}
+	}
+	c.focus = 0
+}
+
+// Gives a mouse event to the focused child, then to the others
+// from the top, skipping layout widgets that were drawn somewhere
+// else. A focusable child that handles the event is focused.
+func (c *container) mouse(children []Widget, ev MouseEvent) bool {
+	for n := -1; n < len(children); n++ {
+		i := c.focus - 1
+		if n >= 0 {
+			i = len(children) - 1 - n
+			if i == c.focus-1 {
+				continue
+			}
+		}
+		if i < 0 || i >= len(children) {
+			continue
+		}
+
+		widget, ok := children[i].(MouseWidget)
+		if !ok {
+			continue
+		}
+		if _, ok := widget.(LayoutWidget); ok && (i >= len(c.areas) || !c.areas[i].Contains(int(ev.X), int(ev.Y))) {
+			continue
+		}
+		if !widget.Mouse(ev) {
+			continue
+		}
+
+		if focusable, ok := widget.(FocusableWidget); ok && i != c.focus-1 {
+			if c.focus > 0 && c.focus <= len(children) {
+				if old, ok := children[c.focus-1].(FocusableWidget); ok {
+					old.Focus(false)
+				}
+			}
+			c.focus = i + 1
+			focusable.Focus(true)
+		}
+		return true
+	}
+	return false
+}
+
+func (c *container) keypress(children []Widget, ch byte) {
+	if c.focus == 0 {
+		c.focusNext(children)
+	}
+	if c.focus > 0 && c.focus <= len(children) {
+		if widget, ok := children[c.focus-1].(FocusableWidget); ok {
+			widget.Keypress(ch)
+		}
+	}
+}
+
+func (c *container) key(children []Widget, key Key) {
+	if c.focus == 0 {
+		c.focusNext(children)
+	}
+	if c.focus > 0 && c.focus <= len(children) {
+		if widget, ok := children[c.focus-1].(KeyWidget); ok {
+			widget.Key(key)
+		}
+	}
+}
+
+// Lays out its children from top to bottom.
+type VBox struct {
+	Children []Widget
+	Spacing  uint16
+
+	container
+}
+
+func (b *VBox) Size() (uint16, uint16) {
+	var width, height uint16
+	for i, child := range b.Children {
+		w, h := sizeOf(child)
+		if w > width {
+			width = w
+		}
+		height += h
+		if i != 0 {
+			height += b.Spacing
+		}
+	}
+	return width, height
+}
+
+func (b *VBox) Place(area Rect) {
+	b.place(area)
+}
+
+func (b *VBox) Draw(buf *Buffer) {
+	area := b.bounds(buf)
+	areas := make([]Rect, len(b.Children))
+	y := area.Y
+	for i, child := range b.Children {
+		_, h := sizeOf(child)
+		areas[i] = Rect{area.X, y, area.Width, h}
+		place(child, areas[i])
+		y += h + b.Spacing
+	}
+	b.drawChildren(b.Children, areas, buf)
+}
+
+func (b *VBox) Focus(focus bool)         { b.setFocus(b.Children, focus) }
+func (b *VBox) FocusNext() bool          { return b.focusNext(b.Children) }
+func (b *VBox) Keypress(ch byte)         { b.keypress(b.Children, ch) }
+func (b *VBox) Key(key Key)              { b.key(b.Children, key) }
+func (b *VBox) Mouse(ev MouseEvent) bool { return b.mouse(b.Children, ev) }
+
+// Lays out its children from left to right.
+type HBox struct {
+	Children []Widget
+	Spacing  uint16
+
+	container
+}
+
+func (b *HBox) Size() (uint16, uint16) {
+	var width, height uint16
+	for i, child := range b.Children {
+		w, h := sizeOf(child)
+		if h > height {
+			height = h
+		}
+		width += w
+		if i != 0 {
+			width += b.Spacing
+		}
+	}
+	return width, height
+}
+
+func (b *HBox) Place(area Rect) {
+	b.place(area)
+}
+
+func (b *HBox) Draw(buf *Buffer) {
+	area := b.bounds(buf)
+	areas := make([]Rect, len(b.Children))
+	x := area.X
+	for i, child := range b.Children {
+		w, _ := sizeOf(child)
+		areas[i] = Rect{x, area.Y, w, area.Height}
+		place(child, areas[i])
+		x += w + b.Spacing
+	}
+	b.drawChildren(b.Children, areas, buf)
+}
+
+func (b *HBox) Focus(focus bool)         { b.setFocus(b.Children, focus) }
+func (b *HBox) FocusNext() bool          { return b.focusNext(b.Children) }
+func (b *HBox) Keypress(ch byte)         { b.keypress(b.Children, ch) }
+func (b *HBox) Key(key Key)              { b.key(b.Children, key) }
+func (b *HBox) Mouse(ev MouseEvent) bool { return b.mouse(b.Children, ev) }
+
+// Lays out its children in rows of Columns cells, from left
+// to right and top to bottom. Each column is as wide as its
+// widest child, and each row as tall as its tallest child.
+type Grid struct {
+	Children []Widget
+	Columns  int
+	Spacing  uint16
+
+	container
+}
+
+// Returns the width of every column and the height of every row.
+func (g *Grid) cells() ([]uint16, []uint16) {
+	columns := g.Columns
+	if columns < 1 {
+		columns = 1
+	}
+
+	widths := make([]uint16, columns)
+	heights := make([]uint16, (len(g.Children)+columns-1)/columns)
+	for i, child := range g.Children {
+		w, h := sizeOf(child)
+		if w > widths[i%columns] {
+			widths[i%columns] = w
+		}
+		if h > heights[i/columns] {
+			heights[i/columns] = h
+		}
+	}
+	return widths, heights
+}
+
+func (g *Grid) Size() (uint16, uint16) {
+	widths, heights := g.cells()
+	var width, height uint16
+	for i, w := range widths {
+		width += w
+		if i != 0 {
+			width += g.Spacing
+		}
+	}
+	for i, h := range heights {
+		height += h
+		if i != 0 {
+			height += g.Spacing
+		}
+	}
+	return width, height
+}
+
+func (g *Grid) Place(area Rect) {
+	g.place(area)
+}
+
+func (g *Grid) Draw(buf *Buffer) {
+	area := g.bounds(buf)
+	widths, heights := g.cells()
+
+	areas := make([]Rect, len(g.Children))
+	y := area.Y
+	for row, h := range heights {
+		x := area.X
+		for col, w := range widths {
+			i := row*len(widths) + col
+			if i >= len(g.Children) {
+				break
+			}
+			areas[i] = Rect{x, y, w, h}
+			place(g.Children[i], areas[i])
+			x += w + g.Spacing
+		}
+		y += h + g.Spacing
+	}
+	g.drawChildren(g.Children, areas, buf)
+}
+
+func (g *Grid) Focus(focus bool)         { g.setFocus(g.Children, focus) }
+func (g *Grid) FocusNext() bool          { return g.focusNext(g.Children) }
+func (g *Grid) Keypress(ch byte)         { g.keypress(g.Children, ch) }
+func (g *Grid) Key(key Key)              { g.key(g.Children, key) }
+func (g *Grid) Mouse(ev MouseEvent) bool { return g.mouse(g.Children, ev) }
+
+// Leaves empty space around its child.
+type Padding struct {
+	Child                    Widget
+	Top, Right, Bottom, Left uint16
+
+	container
+}
+
+func (p *Padding) Size() (uint16, uint16) {
+	w, h := sizeOf(p.Child)
+	return w + p.Left + p.Right, h + p.Top + p.Bottom
+}
+
+func (p *Padding) Place(area Rect) {
+	p.place(area)
+}
+
+func (p *Padding) Draw(buf *Buffer) {
+	area := p.bounds(buf)
+	inner := Rect{
+		area.X + p.Left, area.Y + p.Top,
+		sub(area.Width, p.Left+p.Right), sub(area.Height, p.Top+p.Bottom),
+	}
+	place(p.Child, inner)
+	p.drawChildren([]Widget{p.Child}, []Rect{inner}, buf)
+}
+
+func (p *Padding) Focus(focus bool)         { p.setFocus([]Widget{p.Child}, focus) }
+func (p *Padding) FocusNext() bool          { return p.focusNext([]Widget{p.Child}) }
+func (p *Padding) Keypress(ch byte)         { p.keypress([]Widget{p.Child}, ch) }
+func (p *Padding) Key(key Key)              { p.key([]Widget{p.Child}, key) }
+func (p *Padding) Mouse(ev MouseEvent) bool { return p.mouse([]Widget{p.Child}, ev) }
+
+// Draws a border around its child, with
+// an optional title in the top border.
+type Frame struct {
+	Child  Widget
+	Title  string
+	Format Format
+
+	container
+}
+
+func (f *Frame) Size() (uint16, uint16) {
+	w, h := sizeOf(f.Child)
+	if title := uint16(len(f.Title)) + 4; title > w+2 {
+		w = title - 2
+	}
+	return w + 2, h + 2
+}
+
+func (f *Frame) Place(area Rect) {
+	f.place(area)
+}
+
+func (f *Frame) Draw(buf *Buffer) {
+	area := f.bounds(buf)
+	if area.Width < 2 || area.Height < 2 {
+		return
+	}
+
+	right := int(area.X) + int(area.Width) - 1
+	bottom := int(area.Y) + int(area.Height) - 1
+	buf.Fill(area, ' ', f.Format)
+	for x := int(area.X) + 1; x < right; x++ {
+		buf.SetCell(x, int(area.Y), '-', f.Format)
+		buf.SetCell(x, bottom, '-', f.Format)
+	}
+	for y := int(area.Y) + 1; y < bottom; y++ {
+		buf.SetCell(int(area.X), y, '|', f.Format)
+		buf.SetCell(right, y, '|', f.Format)
+	}
+	for _, x := range []int{int(area.X), right} {
+		buf.SetCell(x, int(area.Y), '+', f.Format)
+		buf.SetCell(x, bottom, '+', f.Format)
+	}
+
+	title := f.Title
+	if len(title) > int(area.Width)-3 {
+		title = title[:maxInt(int(area.Width)-3, 0)]
+	}
+	buf.WriteString(int(area.X)+2, int(area.Y), title, Format{Fg: f.Format.Fg, Bg: f.Format.Bg, Bold: true})
+
+	inner := Rect{area.X + 1, area.Y + 1, area.Width - 2, area.Height - 2}
+	place(f.Child, inner)
+	f.drawChildren([]Widget{f.Child}, []Rect{inner}, buf)
+}
+
+func (f *Frame) Focus(focus bool)         { f.setFocus([]Widget{f.Child}, focus) }
+func (f *Frame) FocusNext() bool          { return f.focusNext([]Widget{f.Child}) }
+func (f *Frame) Keypress(ch byte)         { f.keypress([]Widget{f.Child}, ch) }
+func (f *Frame) Key(key Key)              { f.key([]Widget{f.Child}, key) }
+func (f *Frame) Mouse(ev MouseEvent) bool { return f.mouse([]Widget{f.Child}, ev) }
+
+// Places its child in the middle of the available area.
+type Center struct {
+	Child Widget
+
+	container
+}
+
+func (c *Center) Size() (uint16, uint16) {
+	return sizeOf(c.Child)
+}
+
+func (c *Center) Place(area Rect) {
+	c.place(area)
+}
+
+func (c *Center) Draw(buf *Buffer) {
+	area := c.bounds(buf)
+	w, h := sizeOf(c.Child)
+	inner := Rect{area.X + sub(area.Width, w)/2, area.Y + sub(area.Height, h)/2, w, h}
+	place(c.Child, inner)
+	c.drawChildren([]Widget{c.Child}, []Rect{inner}, buf)
+}
+
+func (c *Center) Focus(focus bool)         { c.setFocus([]Widget{c.Child}, focus) }
+func (c *Center) FocusNext() bool          { return c.focusNext([]Widget{c.Child}) }
+func (c *Center) Keypress(ch byte)         { c.keypress([]Widget{c.Child}, ch) }
+func (c *Center) Key(key Key)              { c.key([]Widget{c.Child}, key) }
+func (c *Center) Mouse(ev MouseEvent) bool { return c.mouse([]Widget{c.Child}, ev) }
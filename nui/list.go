@@ -0,0 +1,381 @@
+package nui
+
+// Type ListItem represents an item of a List.
+type ListItem struct {
+	Text string
+
+	// If set, used instead of the list's Format
+	// when the item is not selected.
+	Format *Format
+}
+
+// Represents a list of items, one per line, of which
+// one is selected. The selected item is drawn with
+// SelectedFormat while the list has focus, and only
+// underlined otherwise. The list scrolls to keep the selected
+// item visible. Arrow keys, Home, End, Page Up and Page Down
+// move the selection, and enter activates the selected item.
+// Clicking an item selects it, and clicking it again activates it.
+//
+// Note: When the event handlers are called,
+// the screen that this widget belongs to is write-locked.
+type List struct {
+	X      uint16
+	Y      uint16
+	Width  uint16
+	Height uint16
+
+	Format         Format
+	SelectedFormat Format
+
+	Items    []ListItem
+	Selected int
+
+	// Called when the selected item changes
+	HandleChange func(idx int)
+	// Called when the selected item is activated
+	HandleSelect func(idx int)
+
+	// Index of the first visible item
+	scroll int
+
+	focused bool
+}
+
+func (l *List) Draw(buf *Buffer) {
+	l.scroll = scrollTo(l.scroll, l.Selected, len(l.Items), int(l.Height))
+
+	for row := 0; row < int(l.Height); row++ {
+		idx := l.scroll + row
+		format := l.Format
+		text := ""
+		if idx < len(l.Items) {
+			text = l.Items[idx].Text
+			if l.Items[idx].Format != nil {
+				format = *l.Items[idx].Format
+			}
+			if idx == l.Selected {
+				format = selectionFormat(l.SelectedFormat, format, l.focused)
+			}
+		}
+		drawRow(buf, l.X, l.Y+uint16(row), l.Width, text, format)
+	}
+
+	if l.Selected >= 0 && l.Selected < len(l.Items) {
+		buf.SetCursor(int(l.X), int(l.Y)+l.Selected-l.scroll, selectionFormat(l.SelectedFormat, l.Format, l.focused))
+	} else {
+		buf.SetCursor(int(l.X), int(l.Y), l.Format)
+	}
+}
+
+func (l *List) Size() (uint16, uint16) {
+	return l.Width, l.Height
+}
+
+func (l *List) Place(area Rect) {
+	l.X, l.Y = area.X, area.Y
+}
+
+func (l *List) Focus(focus bool) {
+	l.focused = focus
+}
+
+func (l *List) Keypress(ch byte) {
+	if ch == '\n' {
+		l.activate()
+	}
+}
+
+func (l *List) Key(key Key) {
+	l.move(moveSelection(l.Selected, len(l.Items), int(l.Height), key))
+}
+
+func (l *List) Mouse(ev MouseEvent) bool {
+	if !(Rect{l.X, l.Y, l.Width, l.Height}).Contains(int(ev.X), int(ev.Y)) || ev.Release {
+		return false
+	}
+
+	if ev.Button == MouseWheelUp {
+		l.move(l.Selected - 1)
+	} else if ev.Button == MouseWheelDown {
+		l.move(l.Selected + 1)
+	} else if ev.Button == MouseLeft {
+		idx := l.scroll + int(ev.Y-l.Y)
+		if idx >= len(l.Items) {
+			return true
+		}
+		if idx == l.Selected {
+			l.activate()
+		} else {
+			l.move(idx)
+		}
+	}
+	return true
+}
+
+func (l *List) move(idx int) {
+	if idx < 0 || idx >= len(l.Items) || idx == l.Selected {
+		return
+	}
+	l.Selected = idx
+	if l.HandleChange != nil {
+		l.HandleChange(idx)
+	}
+}
+
+func (l *List) activate() {
+	if l.Selected >= 0 && l.Selected < len(l.Items) && l.HandleSelect != nil {
+		l.HandleSelect(l.Selected)
+	}
+}
+
+// Type Column represents a column of a Table.
+type Column struct {
+	Title string
+
+	// If zero, the column is as wide as its widest cell.
+	Width uint16
+}
+
+// Represents a table with a header row, of which one row is
+// selected. It is used in the same way as a List, and Height
+// includes the header. Columns are separated by a space.
+//
+// Note: When the event handlers are called,
+// the screen that this widget belongs to is write-locked.
+type Table struct {
+	X      uint16
+	Y      uint16
+	Height uint16
+
+	Format         Format
+	HeaderFormat   Format
+	SelectedFormat Format
+
+	Columns  []Column
+	Rows     [][]string
+	Selected int
+
+	// Called when the selected row changes
+	HandleChange func(idx int)
+	// Called when the selected row is activated
+	HandleSelect func(idx int)
+
+	// Index of the first visible row
+	scroll int
+
+	focused bool
+}
+
+// Returns the width of every column.
+func (t *Table) widths() []uint16 {
+	widths := make([]uint16, len(t.Columns))
+	for i, col := range t.Columns {
+		widths[i] = col.Width
+		if col.Width != 0 {
+			continue
+		}
+
+		widths[i] = uint16(len(col.Title))
+		for _, row := range t.Rows {
+			if i < len(row) && uint16(len(row[i])) > widths[i] {
+				widths[i] = uint16(len(row[i]))
+			}
+		}
+	}
+	return widths
+}
+
+func (t *Table) visibleRows() int {
+	return int(sub(t.Height, 1))
+}
+
+func (t *Table) drawRow(buf *Buffer, y uint16, widths []uint16, cells []string, format Format) {
+	x := t.X
+	for i, w := range widths {
+		text := ""
+		if i < len(cells) {
+			text = cells[i]
+		}
+		drawRow(buf, x, y, w, text, format)
+		if i != len(widths)-1 {
+			buf.SetCell(int(x+w), int(y), ' ', format)
+		}
+		x += w + 1
+	}
+}
+
+func (t *Table) Draw(buf *Buffer) {
+	widths := t.widths()
+	t.scroll = scrollTo(t.scroll, t.Selected, len(t.Rows), t.visibleRows())
+
+	titles := make([]string, len(t.Columns))
+	for i, col := range t.Columns {
+		titles[i] = col.Title
+	}
+	t.drawRow(buf, t.Y, widths, titles, t.HeaderFormat)
+
+	for row := 0; row < t.visibleRows(); row++ {
+		idx := t.scroll + row
+		var cells []string
+		if idx < len(t.Rows) {
+			cells = t.Rows[idx]
+		}
+		format := t.Format
+		if idx == t.Selected {
+			format = selectionFormat(t.SelectedFormat, t.Format, t.focused)
+		}
+		t.drawRow(buf, t.Y+1+uint16(row), widths, cells, format)
+	}
+
+	if t.Selected >= 0 && t.Selected < len(t.Rows) {
+		buf.SetCursor(int(t.X), int(t.Y)+1+t.Selected-t.scroll, selectionFormat(t.SelectedFormat, t.Format, t.focused))
+	} else {
+		buf.SetCursor(int(t.X), int(t.Y), t.HeaderFormat)
+	}
+}
+
+func (t *Table) Size() (uint16, uint16) {
+	var width uint16
+	for i, w := range t.widths() {
+		width += w
+		if i != 0 {
+			width++
+		}
+	}
+	return width, t.Height
+}
+
+func (t *Table) Place(area Rect) {
+	t.X, t.Y = area.X, area.Y
+}
+
+func (t *Table) Focus(focus bool) {
+	t.focused = focus
+}
+
+func (t *Table) Keypress(ch byte) {
+	if ch == '\n' {
+		t.activate()
+	}
+}
+
+func (t *Table) Key(key Key) {
+	t.move(moveSelection(t.Selected, len(t.Rows), t.visibleRows(), key))
+}
+
+func (t *Table) Mouse(ev MouseEvent) bool {
+	width, _ := t.Size()
+	if !(Rect{t.X, t.Y, width, t.Height}).Contains(int(ev.X), int(ev.Y)) || ev.Release {
+		return false
+	}
+
+	if ev.Button == MouseWheelUp {
+		t.move(t.Selected - 1)
+	} else if ev.Button == MouseWheelDown {
+		t.move(t.Selected + 1)
+	} else if ev.Button == MouseLeft && ev.Y > t.Y {
+		idx := t.scroll + int(ev.Y-t.Y-1)
+		if idx >= len(t.Rows) {
+			return true
+		}
+		if idx == t.Selected {
+			t.activate()
+		} else {
+			t.move(idx)
+		}
+	}
+	return true
+}
+
+func (t *Table) move(idx int) {
+	if idx < 0 || idx >= len(t.Rows) || idx == t.Selected {
+		return
+	}
+	t.Selected = idx
+	if t.HandleChange != nil {
+		t.HandleChange(idx)
+	}
+}
+
+func (t *Table) activate() {
+	if t.Selected >= 0 && t.Selected < len(t.Rows) && t.HandleSelect != nil {
+		t.HandleSelect(t.Selected)
+	}
+}
+
+// Returns the format of the selected item of a list, which is
+// only highlighted while the list has focus. Otherwise, the item
+// keeps its format and is underlined.
+func selectionFormat(selected Format, format Format, focused bool) Format {
+	if focused {
+		return selected
+	}
+	format.Underline = true
+	return format
+}
+
+// Draws text padded with spaces, or cut off, to the given width.
+func drawRow(buf *Buffer, x uint16, y uint16, width uint16, text string, format Format) {
+	if len(text) > int(width) {
+		text = text[:width]
+	}
+	buf.Fill(Rect{x, y, width, 1}, ' ', format)
+	buf.WriteString(int(x), int(y), text, format)
+}
+
+// Returns the first visible row after scrolling as little
+// as possible from scroll to show the selected row.
+func scrollTo(scroll int, selected int, count int, height int) int {
+	if selected < scroll {
+		scroll = selected
+	}
+	if selected >= scroll+height {
+		scroll = selected - height + 1
+	}
+	if scroll > count-height {
+		scroll = count - height
+	}
+	if scroll < 0 {
+		scroll = 0
+	}
+	return scroll
+}
+
+// Returns the selected row after a key is pressed,
+// where height is the number of visible rows.
+func moveSelection(selected int, count int, height int, key Key) int {
+	if height < 1 {
+		height = 1
+	}
+
+	switch key {
+	case KeyUp:
+		selected--
+	case KeyDown:
+		selected++
+	case KeyPageUp:
+		selected -= height
+	case KeyPageDown:
+		selected += height
+	case KeyHome:
+		selected = 0
+	case KeyEnd:
+		selected = count - 1
+	}
+
+	if selected >= count {
+		selected = count - 1
+	}
+	if selected < 0 {
+		selected = 0
+	}
+	return selected
+}
+
+func ternaryFormat(cond bool, a Format, b Format) Format {
+	if cond {
+		return a
+	}
+	return b
+}
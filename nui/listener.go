@@ -0,0 +1,96 @@
+package nui
+
+import (
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Type Listener accepts connections from clients.
+// Accept should return an error wrapping net.ErrClosed
+// after the listener has been closed.
+type Listener interface {
+	Accept() (Conn, error)
+	Close() error
+	Addr() net.Addr
+}
+
+// Returns a listener that accepts plain connections from
+// a net.Listener, such as a TCP, Unix socket or TLS listener.
+func NewListener(ln net.Listener) Listener {
+	return netListener{ln}
+}
+
+type netListener struct {
+	net.Listener
+}
+
+func (l netListener) Accept() (Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return netConn{conn}, nil
+}
+
+// Decides whether to keep accepting connections after an error.
+// Temporary errors are retried after waiting for a delay that doubles
+// on every consecutive error; delay should be reset after a success.
+func retryAccept(err error, delay *time.Duration) bool {
+	if errors.Is(err, net.ErrClosed) {
+		return false
+	}
+	if temp, ok := err.(interface{ Temporary() bool }); !ok || !temp.Temporary() {
+		return false
+	}
+
+	if *delay == 0 {
+		*delay = 5 * time.Millisecond
+	} else if *delay *= 2; *delay > time.Second {
+		*delay = time.Second
+	}
+	log.Printf("error accepting connection: %v; retrying in %v\n", err, *delay)
+	time.Sleep(*delay)
+	return true
+}
+
+// Hands connections that are set up in other
+// goroutines over to Accept.
+type connQueue struct {
+	conns  chan Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func newConnQueue() *connQueue {
+	return &connQueue{
+		conns:  make(chan Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+// Waits for the connection to be accepted. Returns
+// false if the queue was closed first.
+func (q *connQueue) push(conn Conn) bool {
+	select {
+	case q.conns <- conn:
+		return true
+	case <-q.closed:
+		return false
+	}
+}
+
+func (q *connQueue) Accept() (Conn, error) {
+	select {
+	case conn := <-q.conns:
+		return conn, nil
+	case <-q.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (q *connQueue) close() {
+	q.once.Do(func() { close(q.closed) })
+}
@@ -1,18 +1,28 @@
 package nui
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
-	"strings"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-func clear(conn net.Conn) {
-	fmt.Fprint(conn, "\x1bc\x1b[49m\x1b[H\x1b[2J\x1b[3J")
+func clear(w io.Writer) {
+	fmt.Fprint(w, "\x1bc\x1b[49m\x1b[H\x1b[2J\x1b[3J"+enableMouse)
+}
+
+// Resets colors, shows the cursor, stops listening for mouse events
+// and moves the cursor below the last row of the screen, so that the
+// terminal is usable again after disconnecting.
+func restore(w io.Writer, height uint16) {
+	fmt.Fprintf(w, "\x1b[0m\x1b[?25h\x1b[?1000l\x1b[?1006l\x1b[%d;1H\r\n", height)
 }
 
 // Type Buffer represents information about
@@ -24,6 +34,12 @@ type Buffer struct {
 
 	CursorX, CursorY uint16
 	CursorFormat     Format
+
+	// Set on views returned by Clip and Sub, which
+	// draw into the area clip of the parent buffer.
+	parent           *Buffer
+	clip             Rect
+	originX, originY uint16
 }
 
 // Buffer initialized to all spaces, with the given background color
@@ -31,8 +47,8 @@ type Buffer struct {
 func emptyBuffer(width uint16, height uint16, bg Color) *Buffer {
 	buffer := &Buffer{
 		Width:   width,
-		Formats: make([]Format, width*height),
-		Chars:   make([]byte, width*height),
+		Formats: make([]Format, int(width)*int(height)),
+		Chars:   make([]byte, int(width)*int(height)),
 	}
 
 	for i, _ := range buffer.Formats {
@@ -45,14 +61,60 @@ func emptyBuffer(width uint16, height uint16, bg Color) *Buffer {
 	return buffer
 }
 
+// Returns a buffer of the given size initialized to all
+// spaces, with the given background color and a Default
+// foreground, such as for drawing screens outside of a server.
+func NewBuffer(width uint16, height uint16, bg Color) *Buffer {
+	return emptyBuffer(width, height, bg)
+}
+
 func (b Buffer) Index(x uint16, y uint16) int {
 	return int(y)*int(b.Width) + int(x)
 }
 
+func (b Buffer) Height() uint16 {
+	if b.Width == 0 {
+		return 0
+	}
+	return uint16(len(b.Chars) / int(b.Width))
+}
+
+// Returns the top-left part of the buffer
+// with the given size.
+func (b *Buffer) crop(width uint16, height uint16) *Buffer {
+	if width == b.Width && int(width)*int(height) == len(b.Chars) {
+		return b
+	}
+
+	cropped := &Buffer{
+		Width:        width,
+		Chars:        make([]byte, 0, int(width)*int(height)),
+		Formats:      make([]Format, 0, int(width)*int(height)),
+		CursorX:      b.CursorX,
+		CursorY:      b.CursorY,
+		CursorFormat: b.CursorFormat,
+	}
+	for y := 0; y < int(height); y++ {
+		start := y * int(b.Width)
+		cropped.Chars = append(cropped.Chars, b.Chars[start:start+int(width)]...)
+		cropped.Formats = append(cropped.Formats, b.Formats[start:start+int(width)]...)
+	}
+	if cropped.CursorX >= width {
+		cropped.CursorX = width - 1
+	}
+	if cropped.CursorY >= height {
+		cropped.CursorY = height - 1
+	}
+	return cropped
+}
+
 type Widget interface {
 	// Draws the widget.
 	// If the widget is focusable, should also
 	// set the correct cursor position.
+	// The screen belonging to the widget is write-locked,
+	// since containers lay out their children and scrolling
+	// widgets keep their position while they are drawn.
 	Draw(buf *Buffer)
 }
 
@@ -77,64 +139,147 @@ type Screen struct {
 	sync.RWMutex
 }
 
-func (s *Screen) Draw(conn net.Conn, oldBuffer *Buffer) *Buffer {
-	newBuffer := emptyBuffer(oldBuffer.Width, uint16(len(oldBuffer.Chars))/oldBuffer.Width, Black)
+// Moves focus to the next focusable widget,
+// going through the children of focus groups.
+func (s *Screen) focusNext() {
+	if s.Focus >= 0 && s.Focus < len(s.Widgets) {
+		if group, ok := s.Widgets[s.Focus].(FocusGroup); ok && group.FocusNext() {
+			return
+		}
+		if widget, ok := s.Widgets[s.Focus].(FocusableWidget); ok {
+			widget.Focus(false)
+		}
+	}
 
-	for idx, widget := range s.Widgets {
-		if idx == s.Focus {
+	for n := 1; n <= len(s.Widgets); n++ {
+		i := (s.Focus + n) % len(s.Widgets)
+		if i < 0 {
+			i += len(s.Widgets)
+		}
+		widget, ok := s.Widgets[i].(FocusableWidget)
+		if !ok {
+			continue
+		}
+		if group, ok := widget.(FocusGroup); ok && !group.FocusNext() {
 			continue
 		}
 
-		widget.Draw(newBuffer)
+		s.Focus = i
+		widget.Focus(true)
+		return
 	}
+}
 
-	if s.Focus >= 0 {
-		s.Widgets[s.Focus].Draw(newBuffer)
+// Gives an input to the widgets of the screen.
+func (s *Screen) handle(clientID int, in input) {
+	if in.mouse != nil {
+		s.mouse(*in.mouse)
+		return
+	}
+	if in.ch == '\t' { // TAB: set focus to next widget
+		s.focusNext()
+		return
+	}
+	if in.ch == 0 && in.key == 0 || s.Focus < 0 || s.Focus >= len(s.Widgets) {
+		return
 	}
 
-	// diff
-	var prevFormat Format
-	//var prevX, prevY uint16
-	firstDraw := true
-	msg := new(strings.Builder)
-	for idx := 0; idx < len(oldBuffer.Chars); idx++ {
-		if oldBuffer.Chars[idx] != newBuffer.Chars[idx] || oldBuffer.Formats[idx] != newBuffer.Formats[idx] {
-			x := uint16(idx % int(oldBuffer.Width))
-			y := uint16(idx / int(oldBuffer.Width))
+	widget, focusable := s.Widgets[s.Focus].(FocusableWidget)
+	if !focusable {
+		log.Println("warning: Focus for client", clientID, "is set to a non-focusable widget", s.Focus)
+		return
+	}
+	if in.key != 0 {
+		if widget, ok := widget.(KeyWidget); ok {
+			widget.Key(in.key)
+		}
+	} else {
+		widget.Keypress(in.ch)
+	}
+}
 
-			//if firstDraw || !(prevX+1 == x && prevY == y) {
-			//	fmt.Fprintf(msg, "\x1b[%d;%dH", y+1, x+1)
-			//}
-			if firstDraw || prevFormat != newBuffer.Formats[idx] {
-				newBuffer.Formats[idx].Apply(msg)
+// Gives a mouse event to the widgets of the screen, starting with
+// the focused one and then from the top. A focusable widget that
+// handles the event is focused.
+func (s *Screen) mouse(ev MouseEvent) {
+	for n := -1; n < len(s.Widgets); n++ {
+		i := s.Focus
+		if n >= 0 {
+			i = len(s.Widgets) - 1 - n
+			if i == s.Focus {
+				continue
 			}
+		}
+		if i < 0 || i >= len(s.Widgets) {
+			continue
+		}
 
-			fmt.Fprintf(msg, "\x1b[%d;%dH", y+1, x+1)
-			//newBuffer.Formats[idx].Apply(msg)
-			fmt.Fprintf(msg, "%c", newBuffer.Chars[idx])
-
-			//prevX = x
-			//prevY = y
-			prevFormat = newBuffer.Formats[idx]
-			//
-			firstDraw = false
+		widget, ok := s.Widgets[i].(MouseWidget)
+		if !ok || !widget.Mouse(ev) {
+			continue
 		}
+		if focusable, ok := widget.(FocusableWidget); ok && i != s.Focus {
+			if s.Focus >= 0 && s.Focus < len(s.Widgets) {
+				if old, ok := s.Widgets[s.Focus].(FocusableWidget); ok {
+					old.Focus(false)
+				}
+			}
+			s.Focus = i
+			focusable.Focus(true)
+		}
+		return
 	}
+}
 
-	newBuffer.CursorFormat.Apply(msg)
-	fmt.Fprintf(msg, "\x1b[%d;%dH", newBuffer.CursorY+1, newBuffer.CursorX+1)
-	fmt.Fprint(conn, msg.String())
+// Draws every widget of the screen onto the buffer,
+// drawing the focused widget last.
+func (s *Screen) Render(buf *Buffer) {
+	for idx, widget := range s.Widgets {
+		if idx == s.Focus {
+			continue
+		}
+
+		widget.Draw(buf)
+	}
 
-	return newBuffer
+	if s.Focus >= 0 && s.Focus < len(s.Widgets) {
+		s.Widgets[s.Focus].Draw(buf)
+	}
 }
 
 type Server struct {
-	ln      net.Listener
-	screens sync.Map /* int => Screen */
+	listeners []Listener
+	clients   int32
+	screens   sync.Map /* int => *screenStack */
+	modes     sync.Map /* int => ColorMode */
+	writers   sync.Map /* int => *writer */
+	views     sync.Map /* int => *writer */
 
 	TermWidth  uint16
 	TermHeight uint16
 
+	// Clients are disconnected if writing a
+	// frame to them takes longer than this.
+	WriteTimeout time.Duration
+
+	// Color mode used for clients that
+	// have not been given one with SetColorMode.
+	ColorMode ColorMode
+
+	// Shown to every client when the server shuts down.
+	ShutdownMessage string
+
+	// If set, everything sent to each client is recorded
+	// to an asciicast file in this directory.
+	RecordDir string
+
+	// Set once Shutdown is called. Guarded by lock.
+	closing bool
+	lock    sync.Mutex
+
+	// Counts the clients that are connected.
+	conns sync.WaitGroup
+
 	// Called when a new client connects.
 	// This function should call SetScreen
 	// and set the screen of the given client.
@@ -142,119 +287,445 @@ type Server struct {
 	HandleDisconnect func(clientID int)
 }
 
-func NewServer(ln net.Listener) *Server {
+func NewServer(listeners ...Listener) *Server {
 	return &Server{
-		ln:         ln,
-		TermWidth:  64,
-		TermHeight: 48,
+		listeners:    listeners,
+		TermWidth:    64,
+		TermHeight:   48,
+		WriteTimeout: 5 * time.Second,
+
+		ShutdownMessage: "The server is shutting down. Goodbye!",
 	}
 }
 
-// Set the screen of a particular client ID
+// Screens shown to a client, from the bottom up. The first
+// one is set with SetScreen, and the others are pushed over it.
+type screenStack struct {
+	screens []*Screen
+	lock    sync.Mutex
+}
+
+func (s *Server) stack(clientID int) *screenStack {
+	v, _ := s.screens.LoadOrStore(clientID, &screenStack{})
+	return v.(*screenStack)
+}
+
+// Returns a copy of the screens of a client, from the bottom up.
+func (s *Server) getScreens(clientID int) []*Screen {
+	v, ok := s.screens.Load(clientID)
+	if !ok {
+		return nil
+	}
+	stack := v.(*screenStack)
+	stack.lock.Lock()
+	defer stack.lock.Unlock()
+	return append([]*Screen(nil), stack.screens...)
+}
+
+// Set the screen of a particular client ID. Screens
+// pushed over the current one are kept over the new one.
 func (s *Server) SetScreen(clientID int, screen *Screen) {
-	s.screens.Store(clientID, screen)
+	stack := s.stack(clientID)
+	stack.lock.Lock()
+	if len(stack.screens) == 0 {
+		stack.screens = append(stack.screens, screen)
+	} else {
+		stack.screens[0] = screen
+	}
+	stack.lock.Unlock()
+	s.Invalidate(clientID)
+}
+
+// Shows a screen over the current screens of a particular
+// client ID, such as a dialog. Only the top screen receives
+// input, but the ones below it are still drawn.
+func (s *Server) PushScreen(clientID int, screen *Screen) {
+	stack := s.stack(clientID)
+	stack.lock.Lock()
+	stack.screens = append(stack.screens, screen)
+	stack.lock.Unlock()
+	s.Invalidate(clientID)
+}
+
+// Removes the top screen of a particular client ID and returns
+// it. The screen set with SetScreen is never removed; nil is
+// returned if there are no other screens.
+func (s *Server) PopScreen(clientID int) *Screen {
+	stack := s.stack(clientID)
+	stack.lock.Lock()
+	if len(stack.screens) < 2 {
+		stack.lock.Unlock()
+		return nil
+	}
+	screen := stack.screens[len(stack.screens)-1]
+	stack.screens = stack.screens[:len(stack.screens)-1]
+	stack.lock.Unlock()
+
+	s.Invalidate(clientID)
+	return screen
+}
+
+// Removes a screen that was pushed for a particular client ID,
+// even if it is not on top. Returns false if it was not found.
+func (s *Server) RemoveScreen(clientID int, screen *Screen) bool {
+	stack := s.stack(clientID)
+	stack.lock.Lock()
+	found := false
+	for i := 1; i < len(stack.screens); i++ {
+		if stack.screens[i] == screen {
+			stack.screens = append(stack.screens[:i], stack.screens[i+1:]...)
+			found = true
+			break
+		}
+	}
+	stack.lock.Unlock()
+
+	if found {
+		s.Invalidate(clientID)
+	}
+	return found
+}
+
+// Removes every screen of a particular client ID and shows
+// only the given one.
+func (s *Server) resetScreens(clientID int, screen *Screen) {
+	stack := s.stack(clientID)
+	stack.lock.Lock()
+	stack.screens = []*Screen{screen}
+	stack.lock.Unlock()
+	s.Invalidate(clientID)
+}
+
+// Redraw the screen of a particular client ID. This should be
+// called after changing any of the widgets of a screen from
+// outside of an event handler.
+func (s *Server) Invalidate(clientID int) {
+	if v, ok := s.writers.Load(clientID); ok {
+		v.(*writer).invalidate()
+	}
+	if v, ok := s.views.Load(clientID); ok {
+		v.(*writer).invalidate()
+	}
 }
 
-// Get the screen of a particular client ID
+// Records what a particular client ID sees to an asciicast file
+// written to w, such as for spectators. The screens are drawn at the
+// given size with all colors, whatever the client's terminal is.
+// Recording stops when StopRecordingView is called, the client
+// disconnects or the server shuts down, which closes w.
+func (s *Server) RecordView(clientID int, w io.WriteCloser, width uint16, height uint16) error {
+	term := Terminal{Width: width, Height: height, Type: "xterm-256color", ColorTerm: "truecolor"}
+	rec, err := NewRecorder(w, term)
+	if err != nil {
+		return err
+	}
+
+	conn := &recordConn{rec: rec, term: term, closed: make(chan struct{})}
+	view := newWriter(conn, s.WriteTimeout, width, height, func(width uint16, height uint16) (*Buffer, ColorMode) {
+		buf, _ := s.render(clientID, width, height)
+		return buf, TrueColor
+	})
+	if _, loaded := s.views.LoadOrStore(clientID, view); loaded {
+		conn.Close()
+		return fmt.Errorf("the view of client %d is already being recorded", clientID)
+	}
+	go view.run()
+	view.invalidate()
+	return nil
+}
+
+// Stops recording the view of a particular client ID.
+func (s *Server) StopRecordingView(clientID int) {
+	if v, ok := s.views.LoadAndDelete(clientID); ok {
+		view := v.(*writer)
+		view.close()
+		view.conn.Close()
+	}
+}
+
+// Starts recording a client's connection to a new file in RecordDir.
+func (s *Server) startRecording(clientID int, w *writer, term Terminal) {
+	name := fmt.Sprintf("client-%d-%s.cast", clientID, time.Now().Format("20060102-150405"))
+	f, err := os.Create(filepath.Join(s.RecordDir, name))
+	if err != nil {
+		log.Println("error creating recording:", err)
+		return
+	}
+	rec, err := NewRecorder(f, term)
+	if err != nil {
+		log.Println("error creating recording:", err)
+		f.Close()
+		return
+	}
+	w.record = rec
+}
+
+// Set the color mode of a particular client ID.
+// The client's terminal is redrawn using the new mode.
+func (s *Server) SetColorMode(clientID int, mode ColorMode) {
+	s.modes.Store(clientID, mode)
+	s.Invalidate(clientID)
+}
+
+// Get the color mode of a particular client ID
+func (s *Server) GetColorMode(clientID int) ColorMode {
+	v, ok := s.modes.Load(clientID)
+	if !ok {
+		return s.ColorMode
+	}
+	return v.(ColorMode)
+}
+
+// Get the screen of a particular client ID that was
+// set with SetScreen, ignoring any pushed over it.
 func (s *Server) GetScreen(clientID int) (*Screen, bool) {
-	v, ok := s.screens.Load(clientID)
+	screens := s.getScreens(clientID)
+	if len(screens) == 0 {
+		return nil, false
+	}
+	return screens[0], true
+}
+
+// Get the top screen of a particular client ID,
+// which is the one that receives input.
+func (s *Server) TopScreen(clientID int) (*Screen, bool) {
+	screens := s.getScreens(clientID)
+	if len(screens) == 0 {
+		return nil, false
+	}
+	return screens[len(screens)-1], true
+}
+
+// Accepts clients from every listener of the server. When ctx is
+// done, the server is shut down, giving clients up to WriteTimeout
+// to receive the farewell screen. Returns once all listeners are closed
+// and all clients have disconnected, with the first error that caused
+// a listener to stop, if any.
+func (s *Server) Run(ctx context.Context) error {
+	errs := make(chan error, len(s.listeners))
+	for _, l := range s.listeners {
+		go func(l Listener) {
+			errs <- s.Serve(l)
+		}(l)
+	}
+
+	stop := make(chan struct{})
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		select {
+		case <-ctx.Done():
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), s.WriteTimeout)
+			defer cancel()
+			s.Shutdown(shutdownCtx)
+		case <-stop:
+		}
+	}()
+
+	var first error
+	for range s.listeners {
+		if err := <-errs; err != nil && first == nil {
+			first = err
+		}
+	}
+	s.conns.Wait()
+	close(stop)
+	<-shutdown
+	return first
+}
+
+// Stops accepting clients, then shows every client ShutdownMessage
+// and disconnects them. Returns once every client has disconnected,
+// or closes the remaining connections and returns ctx's error if ctx
+// is done first.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.lock.Lock()
+	s.closing = true
+	s.lock.Unlock()
+
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	s.writers.Range(func(key, value interface{}) bool {
+		s.disconnect(key.(int), value.(*writer), s.ShutdownMessage)
+		return true
+	})
+
+	done := make(chan struct{})
+	go func() {
+		s.conns.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.writers.Range(func(key, value interface{}) bool {
+			value.(*writer).conn.Close()
+			return true
+		})
+		return ctx.Err()
+	}
+}
+
+// Shows reason to a client, then resets its terminal and closes
+// its connection. Returns false if there is no such client.
+// This may be called from HandleConnect to reject a client.
+func (s *Server) Disconnect(clientID int, reason string) bool {
+	v, ok := s.writers.Load(clientID)
+	if !ok {
+		return false
+	}
+	s.disconnect(clientID, v.(*writer), reason)
+	return true
+}
+
+// Get the address that a particular client ID connected from
+func (s *Server) RemoteAddr(clientID int) (net.Addr, bool) {
+	v, ok := s.writers.Load(clientID)
 	if !ok {
 		return nil, false
 	}
-	return v.(*Screen), true
+	return v.(*writer).conn.RemoteAddr(), true
+}
+
+// Shows a message to the client, then closes its connection.
+func (s *Server) disconnect(clientID int, w *writer, message string) {
+	s.resetScreens(clientID, &Screen{
+		Focus:   -1,
+		Widgets: []Widget{&Label{X: 2, Y: 1, Format: Format{Fg: LightWhite, Bg: Black}, Text: message}},
+	})
+	w.finish()
 }
 
-func (s *Server) Run() {
-	clients := 0
+// Accepts clients from a listener until it is closed. Temporary
+// errors are logged and retried; any other error stops the
+// listener and is returned.
+func (s *Server) Serve(l Listener) error {
+	var delay time.Duration
 	for {
-		conn, err := s.ln.Accept()
+		conn, err := l.Accept()
 		if err != nil {
-			log.Println("error accepting connection:", err)
+			if retryAccept(err, &delay) {
+				continue
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
 		}
-		clients += 1
-		go s.connThread(conn, clients-1)
+		delay = 0
+		s.conns.Add(1)
+		go func() {
+			defer s.conns.Done()
+			s.connThread(conn, s.newClientID())
+		}()
 	}
 }
 
+func (s *Server) newClientID() int {
+	return int(atomic.AddInt32(&s.clients, 1) - 1)
+}
+
+// Renders the current screen of a client. Screens are always
+// drawn at least TermWidth by TermHeight large, and cut off
+// if the client's terminal is smaller.
+func (s *Server) render(clientID int, width uint16, height uint16) (*Buffer, ColorMode) {
+	buf := emptyBuffer(maxUint16(width, s.TermWidth), maxUint16(height, s.TermHeight), Black)
+	for _, screen := range s.getScreens(clientID) {
+		screen.Lock()
+		screen.Render(buf)
+		screen.Unlock()
+	}
+	return buf.crop(width, height), s.GetColorMode(clientID)
+}
+
+func maxUint16(a uint16, b uint16) uint16 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 // Run in a different thread. Mem safety: This function does not
-// write to s.screens and does not read or write from s.ln or s.HandleConnect.
+// write to s.screens and does not read or write from s.listeners or s.HandleConnect.
 // This function only accesses s.screens[clientID] and not any other key-value pair.
-func (s *Server) connThread(conn net.Conn, clientID int) {
-	s.HandleConnect(clientID)
+func (s *Server) connThread(conn Conn, clientID int) {
+	s.lock.Lock()
+	closing := s.closing
+	s.lock.Unlock()
+	if closing {
+		conn.Close()
+		return
+	}
 
-	// Send clear escape codes & codes to listen for mouse events
-	clear(conn)
-	//	fmt.Fprint(conn, "\x1b[?1000h") // mouse events
+	term := conn.Terminal()
+	if mode, ok := term.ColorMode(); ok {
+		s.modes.Store(clientID, mode)
+	}
+	if term.Width == 0 || term.Height == 0 {
+		term.Width, term.Height = s.TermWidth, s.TermHeight
+	}
+	term.Width, term.Height = clampSize(int(term.Width), int(term.Height))
 
-	screenI, ok := s.screens.Load(clientID)
-	if !ok {
+	w := newWriter(conn, s.WriteTimeout, term.Width, term.Height, func(width uint16, height uint16) (*Buffer, ColorMode) {
+		return s.render(clientID, width, height)
+	})
+	if s.RecordDir != "" {
+		s.startRecording(clientID, w, term)
+	}
+	if conn, ok := conn.(ResizableConn); ok {
+		conn.OnResize(w.resize)
+	}
+	s.writers.Store(clientID, w)
+	go w.run()
+
+	s.HandleConnect(clientID)
+
+	if _, ok := s.GetScreen(clientID); !ok {
 		log.Println("no screen found for client ID: ", clientID)
 	}
-	screen := screenI.(*Screen)
 
-	buffer := emptyBuffer(s.TermWidth, s.TermHeight, Default)
-	buffer = screen.Draw(conn, buffer)
+	s.lock.Lock()
+	if s.closing {
+		s.disconnect(clientID, w, s.ShutdownMessage)
+	}
+	s.lock.Unlock()
+	w.invalidate()
 
-	buf := make([]byte, 1)
+	var parser inputParser
+	buf := make([]byte, 256)
 	for {
-		conn.SetReadDeadline(time.Now().Add(time.Millisecond * 100))
-		_, err := conn.Read(buf)
-		if err != nil && !errors.Is(err, os.ErrDeadlineExceeded) {
+		n, err := conn.Read(buf)
+		if err != nil {
 			break
 		}
 
-		screenI, ok := s.screens.Load(clientID)
-		if !ok {
-			continue
-		}
-		screen := screenI.(*Screen)
+		for _, in := range parser.parse(buf[:n]) {
+			// An input can change the top screen,
+			// so it is found again for every input.
+			screen, ok := s.TopScreen(clientID)
+			if !ok {
+				continue
+			}
 
-		if err == nil {
 			screen.Lock()
-			c := buf[0]
-			if c == '\t' { // TAB: set focus to next widget
-				if screen.Focus >= 0 {
-					if widget, focusable := screen.Widgets[screen.Focus].(FocusableWidget); focusable {
-						screen.Unlock()
-						widget.Focus(true)
-						screen.Lock()
-					}
-				}
-
-				endIdx := (screen.Focus + 1) % len(screen.Widgets)
-				if endIdx < 0 {
-					endIdx = 0
-				}
-
-				first := true
-				for i := endIdx; first || i != endIdx; i = (i + 1) % len(screen.Widgets) {
-					if widget, focusable := screen.Widgets[i].(FocusableWidget); focusable {
-						widget.Focus(true)
-
-						screen.Focus = i
-						break
-					}
-				}
-			} else if c == '\x1b' {
-				// TODO
-			} else {
-				if screen.Focus >= 0 {
-					if widget, focusable := screen.Widgets[screen.Focus].(FocusableWidget); focusable {
-						widget.Keypress(c)
-
-						//						log.Printf("delivering keypress to widget: %d", screen.Focus)
-					} else {
-						log.Println("warning: Focus for client", clientID, "is set to a non-focusable widget", screen.Focus)
-					}
-				}
-			}
+			screen.handle(clientID, in)
 			screen.Unlock()
 		}
 
-		screen.RLock()
-		buffer = screen.Draw(conn, buffer)
-		screen.RUnlock()
+		w.invalidate()
 	}
 
+	s.writers.Delete(clientID)
+	w.close()
+	conn.Close()
+	if w.record != nil {
+		w.record.Close()
+	}
+	s.StopRecordingView(clientID)
+	s.modes.Delete(clientID)
 	s.HandleDisconnect(clientID)
+	s.screens.Delete(clientID)
 }
@@ -0,0 +1,284 @@
+package nuitest
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/allen-b1/sus-tux/nui"
+)
+
+// How long Wait and WaitText wait by default
+const DefaultTimeout = 2 * time.Second
+
+// Type Listener is a nui.Listener whose clients
+// are connected with Connect rather than over a network.
+type Listener struct {
+	conns  chan nui.Conn
+	closed chan struct{}
+	once   sync.Once
+}
+
+func NewListener() *Listener {
+	return &Listener{
+		conns:  make(chan nui.Conn),
+		closed: make(chan struct{}),
+	}
+}
+
+// Returns a listener that the server accepts clients
+// from until it is closed.
+func Serve(srv *nui.Server) *Listener {
+	l := NewListener()
+	go srv.Serve(l)
+	return l
+}
+
+func (l *Listener) Accept() (nui.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *Listener) Close() error {
+	l.once.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *Listener) Addr() net.Addr {
+	return pipeAddr{}
+}
+
+type pipeAddr struct{}
+
+func (pipeAddr) Network() string { return "pipe" }
+func (pipeAddr) String() string  { return "nuitest" }
+
+// Connects a client with the given terminal, which must have a
+// size. The server picks colors from its type as it would for a
+// real terminal; use a type such as "xterm-direct" to receive
+// colors exactly as widgets set them. Returns an error if the
+// listener is closed.
+func (l *Listener) Connect(term nui.Terminal) (*Client, error) {
+	if term.Width == 0 || term.Height == 0 {
+		return nil, fmt.Errorf("terminal size must be set")
+	}
+
+	server, client := net.Pipe()
+	conn := &conn{Conn: server, term: term}
+	c := &Client{
+		conn:    client,
+		server:  conn,
+		vt:      NewVT(int(term.Width), int(term.Height)),
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	select {
+	case l.conns <- conn:
+	case <-l.closed:
+		server.Close()
+		client.Close()
+		return nil, net.ErrClosed
+	}
+
+	go c.read()
+	return c, nil
+}
+
+// The server's end of a connection from a client.
+type conn struct {
+	net.Conn
+	term nui.Terminal
+
+	onResize func(uint16, uint16)
+	lock     sync.Mutex
+}
+
+func (c *conn) Terminal() nui.Terminal {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.term
+}
+
+func (c *conn) OnResize(f func(width uint16, height uint16)) {
+	c.lock.Lock()
+	c.onResize = f
+	c.lock.Unlock()
+}
+
+// Type Client is a fake client connected to a nui.Server. What the
+// server sends is interpreted by a virtual terminal as it arrives.
+type Client struct {
+	conn   net.Conn
+	server *conn
+	vt     *VT
+
+	// Closed and replaced every time output arrives
+	changed chan struct{}
+	// Closed once the server closes the connection
+	done chan struct{}
+	lock sync.Mutex
+}
+
+func (c *Client) read() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := c.conn.Read(buf)
+		if n > 0 {
+			c.vt.Write(buf[:n])
+			c.lock.Lock()
+			close(c.changed)
+			c.changed = make(chan struct{})
+			c.lock.Unlock()
+		}
+		if err != nil {
+			close(c.done)
+			return
+		}
+	}
+}
+
+// Returns the client's virtual terminal.
+func (c *Client) VT() *VT {
+	return c.vt
+}
+
+// Returns a channel that is closed once the
+// server has closed the client's connection.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Sends keys, as a terminal would send them when they are typed.
+func (c *Client) Send(keys string) error {
+	_, err := c.conn.Write([]byte(keys))
+	return err
+}
+
+var keySequences = map[nui.Key]string{
+	nui.KeyEscape: "\x1b", nui.KeyUp: "\x1b[A", nui.KeyDown: "\x1b[B",
+	nui.KeyRight: "\x1b[C", nui.KeyLeft: "\x1b[D", nui.KeyHome: "\x1b[H",
+	nui.KeyEnd: "\x1b[F", nui.KeyPageUp: "\x1b[5~", nui.KeyPageDown: "\x1b[6~",
+	nui.KeyInsert: "\x1b[2~", nui.KeyDelete: "\x1b[3~", nui.KeyBacktab: "\x1b[Z",
+}
+
+// Sends the escape sequence of a key.
+func (c *Client) SendKey(key nui.Key) error {
+	return c.Send(keySequences[key])
+}
+
+// Presses and releases a mouse button at a cell.
+func (c *Client) Click(x int, y int, button nui.MouseButton) error {
+	b := int(button)
+	if button == nui.MouseWheelUp || button == nui.MouseWheelDown {
+		b = 64 + int(button-nui.MouseWheelUp)
+		return c.Send(fmt.Sprintf("\x1b[<%d;%d;%dM", b, x+1, y+1))
+	}
+	return c.Send(fmt.Sprintf("\x1b[<%d;%d;%dM\x1b[<%d;%d;%dm", b, x+1, y+1, b, x+1, y+1))
+}
+
+// Resizes the client's terminal and tells the server.
+func (c *Client) Resize(width int, height int) {
+	c.vt.Resize(width, height)
+
+	c.server.lock.Lock()
+	c.server.term.Width, c.server.term.Height = uint16(width), uint16(height)
+	f := c.server.onResize
+	c.server.lock.Unlock()
+
+	if f != nil {
+		f(uint16(width), uint16(height))
+	}
+}
+
+// Waits until cond returns true for the virtual terminal, checking
+// it every time output arrives. Returns false if it does not within
+// DefaultTimeout, or if the connection is closed first.
+func (c *Client) Wait(cond func(vt *VT) bool) bool {
+	return c.WaitTimeout(cond, DefaultTimeout)
+}
+
+// Like Wait, with the given timeout.
+func (c *Client) WaitTimeout(cond func(vt *VT) bool, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		c.lock.Lock()
+		changed := c.changed
+		c.lock.Unlock()
+
+		if cond(c.vt) {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-c.done:
+			return cond(c.vt)
+		case <-timer.C:
+			return false
+		}
+	}
+}
+
+// Waits until text is shown anywhere on the virtual
+// terminal, for at most DefaultTimeout.
+func (c *Client) WaitText(text string) bool {
+	return c.WaitTextTimeout(text, DefaultTimeout)
+}
+
+// Like WaitText, with the given timeout.
+func (c *Client) WaitTextTimeout(text string, timeout time.Duration) bool {
+	return c.WaitTimeout(func(vt *VT) bool {
+		_, _, ok := vt.Find(text)
+		return ok
+	}, timeout)
+}
+
+// Waits until the server sends nothing for the given duration,
+// such as after a key is sent. Returns false if it keeps sending
+// for longer than the timeout.
+func (c *Client) Settle(quiet time.Duration, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		c.lock.Lock()
+		changed := c.changed
+		c.lock.Unlock()
+
+		select {
+		case <-changed:
+		case <-c.done:
+			return true
+		case <-time.After(quiet):
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if text is shown at a cell with the given format.
+// The foreground color of spaces is not compared, since it is not shown.
+func (c *Client) HasText(x int, y int, text string, format nui.Format) bool {
+	for i := 0; i < len(text); i++ {
+		ch, f := c.vt.Cell(x+i, y)
+		if ch != text[i] {
+			return false
+		}
+		if ch == ' ' {
+			f.Fg = format.Fg
+		}
+		if f != format {
+			return false
+		}
+	}
+	return true
+}
+
+// Disconnects the client.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,118 @@
+package nuitest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allen-b1/sus-tux/nui"
+)
+
+var white = nui.Format{Fg: nui.White, Bg: nui.Black}
+
+// Serves a screen with a label, an entry and a button that counts clicks.
+func serveTestScreen(t *testing.T) (*nui.Server, *Listener) {
+	srv := nui.NewServer()
+	srv.HandleConnect = func(clientID int) {
+		label := &nui.Label{X: 1, Y: 0, Format: white, Text: "clicks: 0"}
+		clicks := 0
+		button := &nui.Button{X: 1, Y: 2, Format: white, Text: "Click"}
+		button.HandleClick = func() {
+			clicks++
+			label.Text = "clicks: " + string(rune('0'+clicks))
+		}
+		entry := &nui.Entry{X: 1, Y: 4, Max: 10, Format: nui.Format{Fg: nui.LightRed, Bg: nui.Black}}
+		srv.SetScreen(clientID, &nui.Screen{Focus: 2, Widgets: []nui.Widget{label, button, entry}})
+	}
+	srv.HandleDisconnect = func(clientID int) {}
+
+	l := Serve(srv)
+	t.Cleanup(func() { l.Close() })
+	return srv, l
+}
+
+func connect(t *testing.T, l *Listener) *Client {
+	c, err := l.Connect(nui.Terminal{Width: 80, Height: 24, Type: "xterm-direct"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	if !c.WaitText("clicks: 0") {
+		t.Fatalf("screen was not shown:\n%s", c.VT())
+	}
+	return c
+}
+
+func TestClientSend(t *testing.T) {
+	_, l := serveTestScreen(t)
+	c := connect(t, l)
+
+	c.Send("alice")
+	if !c.Wait(func(vt *VT) bool { return c.HasText(1, 4, "alice", nui.Format{Fg: nui.LightRed, Bg: nui.Black}) }) {
+		t.Errorf("typed text was not shown:\n%s", c.VT())
+	}
+}
+
+func TestClientClick(t *testing.T) {
+	_, l := serveTestScreen(t)
+	c := connect(t, l)
+
+	x, y, ok := c.VT().Find("Click")
+	if !ok {
+		t.Fatalf("button was not shown:\n%s", c.VT())
+	}
+	c.Click(x, y, nui.MouseLeft)
+	if !c.WaitText("clicks: 1") {
+		t.Fatalf("click was not handled:\n%s", c.VT())
+	}
+
+	// Clicks next to the button are not handled by it.
+	c.Click(x+30, y, nui.MouseLeft)
+	c.Click(x, y, nui.MouseLeft)
+	if !c.WaitText("clicks: 2") {
+		t.Errorf("second click was not handled:\n%s", c.VT())
+	}
+}
+
+func TestClientWaitTimeout(t *testing.T) {
+	_, l := serveTestScreen(t)
+	c := connect(t, l)
+
+	start := time.Now()
+	if c.WaitTextTimeout("never shown", 100*time.Millisecond) {
+		t.Error("WaitTextTimeout found text that is not shown")
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Error("WaitTextTimeout returned before its timeout")
+	}
+}
+
+func TestClientDisconnect(t *testing.T) {
+	srv, l := serveTestScreen(t)
+	c := connect(t, l)
+
+	srv.Disconnect(0, "Goodbye.")
+	select {
+	case <-c.Done():
+	case <-time.After(DefaultTimeout):
+		t.Fatal("connection was not closed")
+	}
+	if !c.WaitText("Goodbye.") {
+		t.Errorf("reason was not shown:\n%s", c.VT())
+	}
+	if c.WaitTextTimeout("never shown", time.Minute) {
+		t.Error("Wait found text that is not shown")
+	}
+}
+
+func TestClientResize(t *testing.T) {
+	_, l := serveTestScreen(t)
+	c := connect(t, l)
+
+	c.Resize(100, 30)
+	if w, h := c.VT().Size(); w != 100 || h != 30 {
+		t.Errorf("terminal is %dx%d, want 100x30", w, h)
+	}
+	if !c.WaitText("clicks: 0") {
+		t.Errorf("screen was not redrawn:\n%s", c.VT())
+	}
+}
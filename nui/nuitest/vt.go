@@ -0,0 +1,367 @@
+// Package nuitest connects fake clients to a nui.Server and
+// interprets what the server sends them, so that screens can be
+// checked without a real terminal.
+package nuitest
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/allen-b1/sus-tux/nui"
+)
+
+// Type VT is a virtual terminal. It understands the escape
+// sequences that nui.Server sends and keeps the resulting grid of
+// characters and formats. Its methods are safe to call concurrently.
+type VT struct {
+	width, height int
+	chars         []byte
+	formats       []nui.Format
+
+	x, y int
+	// Set after writing to the last column, so that
+	// the next character wraps to the next line.
+	wrap bool
+	pen  nui.Format
+	// Scrolling region
+	top, bottom int
+
+	// Escape sequence cut off at the end of a write
+	pending []byte
+	lock    sync.Mutex
+}
+
+var defaultFormat = nui.Format{Fg: nui.Default, Bg: nui.Default}
+
+// Returns an empty virtual terminal of the given size.
+func NewVT(width int, height int) *VT {
+	v := &VT{}
+	v.reset(width, height)
+	return v
+}
+
+func (v *VT) reset(width int, height int) {
+	v.width, v.height = width, height
+	v.chars = make([]byte, width*height)
+	v.formats = make([]nui.Format, width*height)
+	v.x, v.y, v.wrap = 0, 0, false
+	v.pen = defaultFormat
+	v.top, v.bottom = 0, height-1
+	v.erase(0, width*height)
+}
+
+// Returns the size of the terminal.
+func (v *VT) Size() (int, int) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return v.width, v.height
+}
+
+// Resizes the terminal, keeping the top-left part of its contents.
+func (v *VT) Resize(width int, height int) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	chars, formats := v.chars, v.formats
+	oldWidth, oldHeight := v.width, v.height
+	v.reset(width, height)
+	for y := 0; y < height && y < oldHeight; y++ {
+		for x := 0; x < width && x < oldWidth; x++ {
+			v.chars[y*width+x] = chars[y*oldWidth+x]
+			v.formats[y*width+x] = formats[y*oldWidth+x]
+		}
+	}
+}
+
+// Returns the character and format of a cell. Cells
+// outside of the terminal are spaces with no format.
+func (v *VT) Cell(x int, y int) (byte, nui.Format) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if x < 0 || y < 0 || x >= v.width || y >= v.height {
+		return ' ', nui.Format{}
+	}
+	return v.chars[y*v.width+x], v.formats[y*v.width+x]
+}
+
+// Returns n characters starting at a cell.
+func (v *VT) Text(x int, y int, n int) string {
+	text := make([]byte, n)
+	for i := range text {
+		text[i], _ = v.Cell(x+i, y)
+	}
+	return string(text)
+}
+
+// Returns a line without trailing spaces.
+func (v *VT) Line(y int) string {
+	width, _ := v.Size()
+	return strings.TrimRight(v.Text(0, y, width), " ")
+}
+
+// Returns every line, without trailing spaces.
+func (v *VT) String() string {
+	_, height := v.Size()
+	lines := make([]string, height)
+	for y := range lines {
+		lines[y] = v.Line(y)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Returns the position of the first cell where text is
+// shown, searching from the top, or false if it is not shown.
+func (v *VT) Find(text string) (int, int, bool) {
+	_, height := v.Size()
+	for y := 0; y < height; y++ {
+		if x := strings.Index(v.Line(y), text); x >= 0 {
+			return x, y, true
+		}
+	}
+	return 0, 0, false
+}
+
+// Returns the position of the cursor.
+func (v *VT) Cursor() (int, int) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	return v.x, v.y
+}
+
+// Interprets output sent to the terminal.
+func (v *VT) Write(p []byte) (int, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	data := append(v.pending, p...)
+	v.pending = nil
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if c != 0x1b {
+			v.control(c)
+			continue
+		}
+
+		n, ok := v.escape(data[i:])
+		if !ok {
+			v.pending = append([]byte(nil), data[i:]...)
+			break
+		}
+		i += n - 1
+	}
+	return len(p), nil
+}
+
+// Handles a character that is not part of an escape sequence.
+func (v *VT) control(c byte) {
+	switch c {
+	case '\r':
+		v.x, v.wrap = 0, false
+	case '\n':
+		v.wrap = false
+		v.lineFeed()
+	case '\b':
+		if v.x > 0 {
+			v.x--
+		}
+		v.wrap = false
+	default:
+		if c < ' ' {
+			return
+		}
+		if v.wrap {
+			v.x, v.wrap = 0, false
+			v.lineFeed()
+		}
+		v.chars[v.y*v.width+v.x] = c
+		v.formats[v.y*v.width+v.x] = v.pen
+		if v.x == v.width-1 {
+			v.wrap = true
+		} else {
+			v.x++
+		}
+	}
+}
+
+func (v *VT) lineFeed() {
+	if v.y == v.bottom {
+		v.scroll(1)
+	} else if v.y < v.height-1 {
+		v.y++
+	}
+}
+
+// Handles the escape sequence at the start of data. Returns
+// its length, or false if data ends before the sequence does.
+func (v *VT) escape(data []byte) (int, bool) {
+	if len(data) < 2 {
+		return 0, false
+	}
+	if data[1] == 'c' {
+		v.reset(v.width, v.height)
+		return 2, true
+	}
+	if data[1] != '[' {
+		return 2, true
+	}
+
+	end := 2
+	for end < len(data) && (data[end] < 0x40 || data[end] > 0x7e) {
+		end++
+	}
+	if end == len(data) {
+		return 0, false
+	}
+	v.csi(string(data[2:end]), data[end])
+	return end + 1, true
+}
+
+func param(params []string, i int, def int) int {
+	if i >= len(params) {
+		return def
+	}
+	n, err := strconv.Atoi(params[i])
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+func clamp(n int, min int, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+// Sets cells from start to end to spaces with the current background.
+func (v *VT) erase(start int, end int) {
+	for i := start; i < end; i++ {
+		v.chars[i] = ' '
+		v.formats[i] = nui.Format{Fg: nui.Default, Bg: v.pen.Bg}
+	}
+}
+
+// Scrolls the scrolling region up by n lines,
+// or down if n is negative.
+func (v *VT) scroll(n int) {
+	w := v.width
+	for ; n > 0; n-- {
+		copy(v.chars[v.top*w:v.bottom*w], v.chars[(v.top+1)*w:(v.bottom+1)*w])
+		copy(v.formats[v.top*w:v.bottom*w], v.formats[(v.top+1)*w:(v.bottom+1)*w])
+		v.erase(v.bottom*w, (v.bottom+1)*w)
+	}
+	for ; n < 0; n++ {
+		copy(v.chars[(v.top+1)*w:(v.bottom+1)*w], v.chars[v.top*w:v.bottom*w])
+		copy(v.formats[(v.top+1)*w:(v.bottom+1)*w], v.formats[v.top*w:v.bottom*w])
+		v.erase(v.top*w, (v.top+1)*w)
+	}
+}
+
+func (v *VT) csi(params string, final byte) {
+	if strings.HasPrefix(params, "?") {
+		// Private modes, such as showing the cursor
+		return
+	}
+
+	ps := strings.Split(params, ";")
+	switch final {
+	case 'A':
+		v.y = clamp(v.y-param(ps, 0, 1), 0, v.height-1)
+	case 'B':
+		v.y = clamp(v.y+param(ps, 0, 1), 0, v.height-1)
+	case 'C':
+		v.x = clamp(v.x+param(ps, 0, 1), 0, v.width-1)
+	case 'D':
+		v.x = clamp(v.x-param(ps, 0, 1), 0, v.width-1)
+	case 'H':
+		v.y = clamp(param(ps, 0, 1)-1, 0, v.height-1)
+		v.x = clamp(param(ps, 1, 1)-1, 0, v.width-1)
+	case 'J':
+		switch param(ps, 0, 0) {
+		case 0:
+			v.erase(v.y*v.width+v.x, len(v.chars))
+		case 1:
+			v.erase(0, v.y*v.width+v.x+1)
+		case 2:
+			v.erase(0, len(v.chars))
+		}
+	case 'K':
+		switch param(ps, 0, 0) {
+		case 0:
+			v.erase(v.y*v.width+v.x, (v.y+1)*v.width)
+		case 1:
+			v.erase(v.y*v.width, v.y*v.width+v.x+1)
+		case 2:
+			v.erase(v.y*v.width, (v.y+1)*v.width)
+		}
+	case 'r':
+		v.top = clamp(param(ps, 0, 1)-1, 0, v.height-1)
+		v.bottom = clamp(param(ps, 1, v.height)-1, v.top, v.height-1)
+		v.x, v.y = 0, 0
+	case 'S':
+		v.scroll(param(ps, 0, 1))
+	case 'T':
+		v.scroll(-param(ps, 0, 1))
+	case 'm':
+		v.sgr(ps)
+	default:
+		return
+	}
+	v.wrap = false
+}
+
+// Sets the format of the characters written after it.
+func (v *VT) sgr(ps []string) {
+	for i := 0; i < len(ps); i++ {
+		n := param(ps, i, 0)
+		switch {
+		case n == 0:
+			v.pen = defaultFormat
+		case n == 1:
+			v.pen.Bold = true
+		case n == 2:
+			v.pen.Dim = true
+		case n == 3:
+			v.pen.Italic = true
+		case n == 4:
+			v.pen.Underline = true
+		case n == 7:
+			v.pen.Reverse = true
+		case n == 22:
+			v.pen.Bold, v.pen.Dim = false, false
+		case n == 23:
+			v.pen.Italic = false
+		case n == 24:
+			v.pen.Underline = false
+		case n == 27:
+			v.pen.Reverse = false
+		case n == 38 || n == 48:
+			var c nui.Color
+			if param(ps, i+1, 0) == 5 {
+				c = nui.Indexed(uint8(param(ps, i+2, 0)))
+				i += 2
+			} else {
+				c = nui.RGB(uint8(param(ps, i+2, 0)), uint8(param(ps, i+3, 0)), uint8(param(ps, i+4, 0)))
+				i += 4
+			}
+			if n == 38 {
+				v.pen.Fg = c
+			} else {
+				v.pen.Bg = c
+			}
+		case n >= 30 && n <= 39:
+			v.pen.Fg = nui.Color(n - 30)
+		case n >= 40 && n <= 49:
+			v.pen.Bg = nui.Color(n - 40)
+		case n >= 90 && n <= 97:
+			v.pen.Fg = nui.Color(n - 30)
+		case n >= 100 && n <= 107:
+			v.pen.Bg = nui.Color(n - 40)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package nuitest
+
+import (
+	"testing"
+
+	"github.com/allen-b1/sus-tux/nui"
+)
+
+func TestVTText(t *testing.T) {
+	vt := NewVT(10, 4)
+	vt.Write([]byte("ab\x1b[3;5Hcd\r\ne"))
+	if vt.Line(0) != "ab" || vt.Line(2) != "    cd" || vt.Line(3) != "e" {
+		t.Errorf("unexpected contents:\n%s", vt)
+	}
+	if x, y := vt.Cursor(); x != 1 || y != 3 {
+		t.Errorf("cursor at (%d, %d), want (1, 3)", x, y)
+	}
+	if x, y, ok := vt.Find("cd"); !ok || x != 4 || y != 2 {
+		t.Errorf("Find returned (%d, %d, %v)", x, y, ok)
+	}
+}
+
+func TestVTWrap(t *testing.T) {
+	vt := NewVT(4, 3)
+	vt.Write([]byte("abcd"))
+	if x, y := vt.Cursor(); x != 3 || y != 0 {
+		t.Errorf("cursor at (%d, %d) after filling a row, want (3, 0)", x, y)
+	}
+	vt.Write([]byte("ef"))
+	if vt.Line(0) != "abcd" || vt.Line(1) != "ef" {
+		t.Errorf("unexpected contents:\n%s", vt)
+	}
+}
+
+func TestVTFormats(t *testing.T) {
+	vt := NewVT(10, 1)
+	vt.Write([]byte("\x1b[1;31;44mA\x1b[22;39;49;4mB\x1b[0;38;5;196;48;2;1;2;3mC\x1b[0;97;100;7mD"))
+
+	want := []nui.Format{
+		{Fg: nui.Red, Bg: nui.Blue, Bold: true},
+		{Fg: nui.Default, Bg: nui.Default, Underline: true},
+		{Fg: nui.Indexed(196), Bg: nui.RGB(1, 2, 3)},
+		{Fg: nui.LightWhite, Bg: nui.LightBlack, Reverse: true},
+	}
+	for x, format := range want {
+		if _, f := vt.Cell(x, 0); f != format {
+			t.Errorf("cell %d has format %v, want %v", x, f, format)
+		}
+	}
+}
+
+func TestVTErase(t *testing.T) {
+	vt := NewVT(6, 3)
+	vt.Write([]byte("aaaaaa\r\nbbbbbb\r\ncccccc"))
+	vt.Write([]byte("\x1b[1;3H\x1b[K\x1b[2;3H\x1b[1K\x1b[3;1H\x1b[42m\x1b[2K"))
+	if vt.Line(0) != "aa" || vt.Line(1) != "   bbb" || vt.Line(2) != "" {
+		t.Errorf("unexpected contents:\n%s", vt)
+	}
+	if _, f := vt.Cell(0, 2); f.Bg != nui.Green {
+		t.Errorf("erased cell has background %v, want %v", f.Bg, nui.Green)
+	}
+
+	vt.Write([]byte("\x1b[2J"))
+	if vt.String() != "\n\n" {
+		t.Errorf("unexpected contents after clearing:\n%s", vt)
+	}
+}
+
+func TestVTScroll(t *testing.T) {
+	vt := NewVT(3, 5)
+	vt.Write([]byte("1\r\n2\r\n3\r\n4\r\n5"))
+	vt.Write([]byte("\x1b[2;4r\x1b[S\x1b[r"))
+	if vt.String() != "1\n3\n4\n\n5" {
+		t.Errorf("unexpected contents after scrolling up:\n%s", vt)
+	}
+	vt.Write([]byte("\x1b[1;3r\x1b[T\x1b[r"))
+	if vt.String() != "\n1\n3\n\n5" {
+		t.Errorf("unexpected contents after scrolling down:\n%s", vt)
+	}
+}
+
+func TestVTSplitSequence(t *testing.T) {
+	vt := NewVT(5, 3)
+	vt.Write([]byte("\x1b["))
+	vt.Write([]byte("2;3"))
+	vt.Write([]byte("Hx\x1b"))
+	vt.Write([]byte("c"))
+	vt.Write([]byte("y"))
+	if vt.String() != "y\n\n" {
+		t.Errorf("unexpected contents:\n%s", vt)
+	}
+}
+
+func TestVTResize(t *testing.T) {
+	vt := NewVT(4, 2)
+	vt.Write([]byte("abcd\r\nefgh"))
+	vt.Resize(2, 3)
+	if w, h := vt.Size(); w != 2 || h != 3 {
+		t.Errorf("size is %dx%d, want 2x3", w, h)
+	}
+	if vt.String() != "ab\nef\n" {
+		t.Errorf("unexpected contents:\n%s", vt)
+	}
+}
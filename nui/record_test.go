@@ -0,0 +1,76 @@
+package nui_test
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allen-b1/sus-tux/nui"
+	"github.com/allen-b1/sus-tux/nui/nuitest"
+)
+
+type closeBuffer struct {
+	bytes.Buffer
+	lock sync.Mutex
+}
+
+func (b *closeBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.Buffer.Write(p)
+}
+
+func (b *closeBuffer) contains(text string) bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return bytes.Contains(b.Bytes(), []byte(text))
+}
+
+func (b *closeBuffer) Close() error {
+	return nil
+}
+
+// Draws a client's screen, which lays out its widgets while it is
+// drawn, for the client and for a recording of its view at once.
+func TestRecordViewWhileDrawing(t *testing.T) {
+	format := nui.Format{Fg: nui.White, Bg: nui.Black}
+	srv := nui.NewServer()
+	srv.HandleConnect = func(clientID int) {
+		srv.SetScreen(clientID, &nui.Screen{Widgets: []nui.Widget{
+			&nui.VBox{Children: []nui.Widget{
+				&nui.Label{Format: format, Text: "first"},
+				&nui.Label{Format: format, Text: "second"},
+			}},
+		}})
+	}
+	srv.HandleDisconnect = func(clientID int) {}
+	l := nuitest.Serve(srv)
+	defer l.Close()
+
+	c, err := l.Connect(nui.Terminal{Width: 80, Height: 24})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if !c.WaitText("second") {
+		t.Fatalf("screen was not shown:\n%s", c.VT())
+	}
+
+	rec := new(closeBuffer)
+	if err := srv.RecordView(0, rec, 80, 24); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.StopRecordingView(0)
+	for i := 0; i < 200; i++ {
+		srv.Invalidate(0)
+	}
+
+	deadline := time.Now().Add(nuitest.DefaultTimeout)
+	for !rec.contains("second") {
+		if time.Now().After(deadline) {
+			t.Fatal("recording does not show the screen")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
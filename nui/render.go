@@ -0,0 +1,224 @@
+package nui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Unchanged cells are rewritten instead of moving
+// the cursor over them if there are at most this many.
+const maxGap = 3
+
+// Rows are erased with a single escape code instead of
+// being overwritten if the blank part is at least this long.
+const minErase = 5
+
+// Tracks what the terminal's cursor and format
+// are while writing a frame.
+type renderer struct {
+	msg  *strings.Builder
+	mode ColorMode
+
+	width  int
+	height int
+
+	// Cursor position, or -1 if unknown (such as after
+	// writing to the last column of a row).
+	x, y int
+	pen  Format
+}
+
+func (r *renderer) move(x, y int) {
+	if r.x == x && r.y == y {
+		return
+	}
+
+	if r.y == y && r.x >= 0 && x > r.x {
+		if x-r.x == 1 {
+			fmt.Fprint(r.msg, "\x1b[C")
+		} else {
+			fmt.Fprintf(r.msg, "\x1b[%dC", x-r.x)
+		}
+	} else if x == 0 && r.y >= 0 && y == r.y+1 {
+		fmt.Fprint(r.msg, "\r\n")
+	} else {
+		fmt.Fprintf(r.msg, "\x1b[%d;%dH", y+1, x+1)
+	}
+	r.x, r.y = x, y
+}
+
+func (r *renderer) setFormat(f Format) {
+	f.applyFrom(r.msg, r.pen, r.mode)
+	r.pen = f
+}
+
+func (r *renderer) put(ch byte, f Format) {
+	r.setFormat(f)
+	r.msg.WriteByte(ch)
+	r.x++
+	if r.x >= r.width {
+		r.x, r.y = -1, -1
+	}
+}
+
+func (r *renderer) eraseLine(f Format) {
+	r.setFormat(f)
+	fmt.Fprint(r.msg, "\x1b[K")
+}
+
+// Whether a blank cell with this format can be
+// produced by erasing.
+func erasable(f Format) bool {
+	return !f.Underline && !f.Reverse
+}
+
+// Returns the column from which the given row
+// consists only of spaces of the same format.
+func blankFrom(buf *Buffer, y int) int {
+	start := y * int(buf.Width)
+	end := start + int(buf.Width)
+	f := buf.Formats[end-1]
+	x := end
+	for x > start && buf.Chars[x-1] == ' ' && buf.Formats[x-1] == f {
+		x--
+	}
+	return x - start
+}
+
+func sameCells(a *Buffer, ay int, b *Buffer, by int) int {
+	width := int(a.Width)
+	n := 0
+	for x := 0; x < width; x++ {
+		if a.Chars[ay*width+x] == b.Chars[by*width+x] && a.Formats[ay*width+x] == b.Formats[by*width+x] {
+			n++
+		}
+	}
+	return n
+}
+
+// Looks for a range of rows that moved up (dir = 1) or down (dir = -1)
+// by one line between oldBuffer and newBuffer. Returns the range
+// [top, bottom] in newBuffer, and the number of cells saved by scrolling.
+func findScroll(oldBuffer *Buffer, newBuffer *Buffer, height int, dir int) (int, int, int) {
+	bestTop, bestBottom, best := 0, 0, 0
+	top, sum := -1, 0
+	for y := 0; y < height; y++ {
+		if y+dir < 0 || y+dir >= height {
+			top, sum = -1, 0
+			continue
+		}
+
+		score := sameCells(newBuffer, y, oldBuffer, y+dir) - sameCells(newBuffer, y, oldBuffer, y)
+		if top < 0 || sum+score < 0 {
+			top, sum = y, 0
+		}
+		sum += score
+		if sum > best {
+			bestTop, bestBottom, best = top, y, sum
+		}
+	}
+	return bestTop, bestBottom, best
+}
+
+// Scrolls the rows [top, bottom] of the terminal by one line up (dir = 1)
+// or down (dir = -1), and updates buf to match. The exposed line is
+// filled with zero bytes so that it is always redrawn.
+func (r *renderer) scroll(buf *Buffer, top int, bottom int, dir int) {
+	fmt.Fprintf(r.msg, "\x1b[%d;%dr", top+1, bottom+1)
+	if dir > 0 {
+		fmt.Fprint(r.msg, "\x1b[S")
+	} else {
+		fmt.Fprint(r.msg, "\x1b[T")
+	}
+	fmt.Fprint(r.msg, "\x1b[r")
+	r.x, r.y = 0, 0
+
+	width := int(buf.Width)
+	exposed := bottom
+	if dir > 0 {
+		copy(buf.Chars[top*width:bottom*width], buf.Chars[(top+1)*width:(bottom+1)*width])
+		copy(buf.Formats[top*width:bottom*width], buf.Formats[(top+1)*width:(bottom+1)*width])
+	} else {
+		copy(buf.Chars[(top+1)*width:(bottom+1)*width], buf.Chars[top*width:bottom*width])
+		copy(buf.Formats[(top+1)*width:(bottom+1)*width], buf.Formats[top*width:bottom*width])
+		exposed = top
+	}
+	for x := 0; x < width; x++ {
+		buf.Chars[exposed*width+x] = 0
+	}
+}
+
+// Writes the escape codes needed to turn a terminal
+// showing oldBuffer into one showing newBuffer. The terminal's
+// cursor and format are assumed to be the ones of oldBuffer.
+func writeDiff(msg *strings.Builder, oldBuffer *Buffer, newBuffer *Buffer, mode ColorMode) {
+	width := int(newBuffer.Width)
+	height := len(newBuffer.Chars) / width
+	r := &renderer{
+		msg: msg, mode: mode,
+		width: width, height: height,
+		x: int(oldBuffer.CursorX), y: int(oldBuffer.CursorY),
+		pen: oldBuffer.CursorFormat,
+	}
+
+	// Scrolling is only worth it if it saves
+	// more than a couple of rows' worth of cells.
+	upTop, upBottom, up := findScroll(oldBuffer, newBuffer, height, 1)
+	downTop, downBottom, down := findScroll(oldBuffer, newBuffer, height, -1)
+	if up > 2*width || down > 2*width {
+		oldBuffer = &Buffer{
+			Width:   oldBuffer.Width,
+			Chars:   append([]byte(nil), oldBuffer.Chars...),
+			Formats: append([]Format(nil), oldBuffer.Formats...),
+		}
+		if up >= down {
+			r.scroll(oldBuffer, upTop, upBottom+1, 1)
+		} else {
+			r.scroll(oldBuffer, downTop-1, downBottom, -1)
+		}
+	}
+
+	changed := func(idx int) bool {
+		return oldBuffer.Chars[idx] != newBuffer.Chars[idx] || oldBuffer.Formats[idx] != newBuffer.Formats[idx]
+	}
+
+	for y := 0; y < height; y++ {
+		blank := blankFrom(newBuffer, y)
+		for x := 0; x < width; x++ {
+			idx := y*width + x
+			if !changed(idx) {
+				continue
+			}
+
+			if x >= blank && width-x >= minErase && erasable(newBuffer.Formats[idx]) {
+				r.move(x, y)
+				r.eraseLine(newBuffer.Formats[idx])
+				break
+			}
+
+			// Bridge short runs of unchanged cells by
+			// rewriting them, as long as no format changes
+			// are needed.
+			if r.y == y && r.x >= 0 && r.x < x && x-r.x <= maxGap {
+				bridge := true
+				for i := r.x; i < x; i++ {
+					if newBuffer.Formats[y*width+i] != r.pen {
+						bridge = false
+						break
+					}
+				}
+				if bridge {
+					for i := r.x; i < x; i++ {
+						r.put(newBuffer.Chars[y*width+i], r.pen)
+					}
+				}
+			}
+
+			r.move(x, y)
+			r.put(newBuffer.Chars[idx], newBuffer.Formats[idx])
+		}
+	}
+
+	r.setFormat(newBuffer.CursorFormat)
+	r.move(int(newBuffer.CursorX), int(newBuffer.CursorY))
+}
@@ -0,0 +1,131 @@
+package nui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const benchWidth, benchHeight = 132, 43
+
+// Writes every cell of newBuffer after clearing the terminal.
+func writeFull(msg *strings.Builder, oldBuffer *Buffer, newBuffer *Buffer, mode ColorMode) {
+	clear(msg)
+	newBuffer.Formats[0].Apply(msg, mode)
+	prev := newBuffer.Formats[0]
+	for idx, ch := range newBuffer.Chars {
+		if newBuffer.Formats[idx] != prev {
+			newBuffer.Formats[idx].Apply(msg, mode)
+			prev = newBuffer.Formats[idx]
+		}
+		msg.WriteByte(ch)
+	}
+}
+
+// Writes every changed cell with its own cursor move,
+// which is how frames were written before writeDiff.
+func writeCells(msg *strings.Builder, oldBuffer *Buffer, newBuffer *Buffer, mode ColorMode) {
+	var prev Format
+	first := true
+	for idx := range newBuffer.Chars {
+		if oldBuffer.Chars[idx] == newBuffer.Chars[idx] && oldBuffer.Formats[idx] == newBuffer.Formats[idx] {
+			continue
+		}
+		if first || prev != newBuffer.Formats[idx] {
+			newBuffer.Formats[idx].Apply(msg, mode)
+		}
+		fmt.Fprintf(msg, "\x1b[%d;%dH%c", idx/int(newBuffer.Width)+1, idx%int(newBuffer.Width)+1, newBuffer.Chars[idx])
+		prev, first = newBuffer.Formats[idx], false
+	}
+}
+
+// Returns lobby frames in which the host types a name, one letter per frame.
+func lobbyFrames() []*Buffer {
+	var frames []*Buffer
+	name := "Someone"
+	for n := 0; n <= len(name); n++ {
+		buf := emptyBuffer(benchWidth, benchHeight, Black)
+		buf.WriteString(8, 4, "Players: 6", Format{Fg: LightWhite, Bg: Black, Underline: true})
+		buf.WriteString(8, 5, name[:n], Format{Fg: Red, Bg: Black, Bold: true})
+		for i := 1; i < 6; i++ {
+			buf.WriteString(8, 5+i, fmt.Sprintf("Player %d", i+1), Format{Fg: Color(i + 1), Bg: Black})
+		}
+		for i, text := range []string{"Start", "Add bot", "Remove bot", "Colors: 256"} {
+			buf.WriteString(64, 6+4*i, " "+text+" ", Format{Fg: LightWhite, Bg: Blue})
+		}
+		buf.SetCursor(8+n, 5, Format{Fg: Red, Bg: Black, Bold: true})
+		frames = append(frames, buf)
+	}
+	return frames
+}
+
+// Returns game frames in which the view follows a player
+// walking across a map with walls, stations and other players.
+func gameFrames(dx int, dy int) []*Buffer {
+	cell := func(x int, y int) byte {
+		if x%30 == 0 && y%12 != 6 || y%15 == 0 && x%30 != 15 {
+			return '+'
+		}
+		if x%23 == 7 && y%11 == 4 {
+			return 'w'
+		}
+		return ' '
+	}
+
+	var frames []*Buffer
+	for i := 0; i < 16; i++ {
+		buf := emptyBuffer(benchWidth, benchHeight, Black)
+		buf.WriteString(2, 1, "Player 1", Format{Fg: Red, Bg: Black})
+		buf.WriteString(2, 2, "Role: Crewmate  Tasks: 1/3", Format{Fg: White, Bg: Black})
+		for y := 4; y < benchHeight; y++ {
+			for x := 0; x < benchWidth; x++ {
+				ch := cell(x+i*dx, y+i*dy)
+				f := Format{Fg: White, Bg: Black}
+				if ch == 'w' {
+					f.Fg = LightYellow
+				}
+				buf.SetCell(x, y, ch, f)
+			}
+		}
+		for p := 0; p < 5; p++ {
+			buf.SetCell(40+p*11+i%3, 10+p*5, 'o', Format{Fg: Color(p + 1), Bg: Black, Bold: true})
+		}
+		buf.SetCell(benchWidth/2, (benchHeight+4)/2, 'o', Format{Fg: Red, Bg: Black, Bold: true})
+		frames = append(frames, buf)
+	}
+	return frames
+}
+
+func benchmarkFrames(b *testing.B, frames []*Buffer) {
+	writers := []struct {
+		name  string
+		write func(*strings.Builder, *Buffer, *Buffer, ColorMode)
+	}{
+		{"full", writeFull},
+		{"cells", writeCells},
+		{"diff", writeDiff},
+	}
+	for _, w := range writers {
+		b.Run(w.name, func(b *testing.B) {
+			total := 0
+			for i := 0; i < b.N; i++ {
+				msg := new(strings.Builder)
+				w.write(msg, frames[i%len(frames)], frames[(i+1)%len(frames)], Colors256)
+				total += msg.Len()
+			}
+			b.ReportMetric(float64(total)/float64(b.N), "bytes/frame")
+		})
+	}
+}
+
+func BenchmarkLobbyFrame(b *testing.B) {
+	benchmarkFrames(b, lobbyFrames())
+}
+
+func BenchmarkGameFrameWalkRight(b *testing.B) {
+	benchmarkFrames(b, gameFrames(1, 0))
+}
+
+func BenchmarkGameFrameWalkDown(b *testing.B) {
+	benchmarkFrames(b, gameFrames(0, 1))
+}
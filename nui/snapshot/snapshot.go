@@ -0,0 +1,171 @@
+// Package snapshot compares what nui screens draw against golden
+// files, so that changes to how they look are caught by tests.
+//
+// A snapshot is a readable text file: the characters of every row,
+// followed by the same grid with a key for the format of every cell,
+// and a legend of what the keys mean. Run tests with -update to write
+// the current snapshots to the golden files instead of comparing them.
+//
+// The package is only meant for tests. Packages that use it call Main
+// from their TestMain, which registers the -update flag.
+package snapshot
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/allen-b1/sus-tux/nui"
+)
+
+// Set by the -update flag
+var update bool
+
+// Registers the -update flag and runs the tests.
+// Call it from the TestMain of a package that uses snapshots.
+func Main(m *testing.M) {
+	flag.BoolVar(&update, "update", false, "write snapshots to the golden files instead of comparing them")
+	flag.Parse()
+	os.Exit(m.Run())
+}
+
+// Directory of the golden files, relative to the
+// directory of the package that is being tested.
+var Dir = filepath.Join("testdata", "snapshots")
+
+// Keys for formats, in the order they are given out.
+const keys = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!@#$%^&*()-=_+[]{};:,.<>/?~"
+
+// Draws a screen into a new buffer of the given size,
+// in the same way as nui.Server does for a client.
+func Draw(screen *nui.Screen, width uint16, height uint16) *nui.Buffer {
+	buf := nui.NewBuffer(width, height, nui.Black)
+	screen.Lock()
+	screen.Render(buf)
+	screen.Unlock()
+	return buf
+}
+
+// Returns the snapshot of a buffer.
+func Encode(buf *nui.Buffer) string {
+	width, height := int(buf.Width), int(buf.Height())
+
+	var legend []nui.Format
+	formatKeys := make(map[nui.Format]byte)
+	key := func(f nui.Format) byte {
+		if k, ok := formatKeys[f]; ok {
+			return k
+		}
+		k := byte('?')
+		if len(legend) < len(keys) {
+			k = keys[len(legend)]
+		}
+		formatKeys[f] = k
+		legend = append(legend, f)
+		return k
+	}
+
+	var text, formats strings.Builder
+	for y := 0; y < height; y++ {
+		text.WriteByte('|')
+		formats.WriteByte('|')
+		for x := 0; x < width; x++ {
+			idx := buf.Index(uint16(x), uint16(y))
+			ch := buf.Chars[idx]
+			if ch < ' ' || ch > '~' {
+				ch = '.'
+			}
+			text.WriteByte(ch)
+			formats.WriteByte(key(buf.Formats[idx]))
+		}
+		text.WriteString("|\n")
+		formats.WriteString("|\n")
+	}
+
+	var s strings.Builder
+	fmt.Fprintf(&s, "size %dx%d\n", width, height)
+	fmt.Fprintf(&s, "cursor %d,%d %v\n", buf.CursorX, buf.CursorY, buf.CursorFormat)
+	s.WriteString("\ntext:\n")
+	s.WriteString(text.String())
+	s.WriteString("\nformats:\n")
+	s.WriteString(formats.String())
+	s.WriteString("\nlegend:\n")
+	for i, f := range legend {
+		k := byte('?')
+		if i < len(keys) {
+			k = keys[i]
+		}
+		fmt.Fprintf(&s, "%c %v\n", k, f)
+	}
+	return s.String()
+}
+
+// Compares the snapshot of a buffer with the golden file of
+// the given name, failing the test if they differ. With -update,
+// the golden file is written instead.
+func Match(t testing.TB, name string, buf *nui.Buffer) {
+	t.Helper()
+
+	got := Encode(buf)
+	path := filepath.Join(Dir, name+".snap")
+	if update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("creating snapshot directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("writing snapshot: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("snapshot %s does not exist; run the test with -update to create it", path)
+	} else if err != nil {
+		t.Fatalf("reading snapshot: %v", err)
+	}
+
+	if diff := Diff(string(want), got); diff != "" {
+		t.Errorf("snapshot %s does not match; run the test with -update if the change is expected:\n%s", path, diff)
+	}
+}
+
+// Like Match, but draws a screen first.
+func MatchScreen(t testing.TB, name string, screen *nui.Screen, width uint16, height uint16) {
+	t.Helper()
+	Match(t, name, Draw(screen, width, height))
+}
+
+// Returns the lines that differ between two snapshots,
+// or an empty string if they are the same.
+func Diff(want string, got string) string {
+	if want == got {
+		return ""
+	}
+
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	n := len(wantLines)
+	if len(gotLines) > n {
+		n = len(gotLines)
+	}
+
+	var s strings.Builder
+	for i := 0; i < n; i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			fmt.Fprintf(&s, "line %d:\n- %s\n+ %s\n", i+1, w, g)
+		}
+	}
+	return s.String()
+}
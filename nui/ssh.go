@@ -0,0 +1,261 @@
+package nui
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// Returns an SSH server configuration that accepts every user
+// without authentication and identifies the server with the given
+// host key. If hostKeyPath is empty, a new key is generated; otherwise
+// the key is read from the file, which is created if it does not exist.
+func NewSSHConfig(hostKeyPath string) (*ssh.ServerConfig, error) {
+	var data []byte
+	var err error
+	if hostKeyPath != "" {
+		data, err = os.ReadFile(hostKeyPath)
+		if errors.Is(err, os.ErrNotExist) {
+			data, err = generateHostKey()
+			if err == nil {
+				err = os.WriteFile(hostKeyPath, data, 0600)
+			}
+		}
+	} else {
+		data, err = generateHostKey()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+	return config, nil
+}
+
+// Returns a new ed25519 key in the PKCS #8 PEM format.
+func generateHostKey() ([]byte, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// Returns a listener that accepts SSH connections from ln,
+// and accepts every interactive session as a client.
+func NewSSHListener(ln net.Listener, config *ssh.ServerConfig) Listener {
+	l := &sshListener{ln: ln, config: config, connQueue: newConnQueue()}
+	go l.run()
+	return l
+}
+
+type sshListener struct {
+	ln     net.Listener
+	config *ssh.ServerConfig
+	*connQueue
+}
+
+func (l *sshListener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+func (l *sshListener) Close() error {
+	l.close()
+	return l.ln.Close()
+}
+
+func (l *sshListener) run() {
+	defer l.close()
+
+	var delay time.Duration
+	for {
+		conn, err := l.ln.Accept()
+		if err != nil {
+			if retryAccept(err, &delay) {
+				continue
+			}
+			if !errors.Is(err, net.ErrClosed) {
+				log.Println("error accepting ssh connection:", err)
+			}
+			return
+		}
+		delay = 0
+		go l.handleConn(conn)
+	}
+}
+
+func (l *sshListener) handleConn(conn net.Conn) {
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, l.config)
+	if err != nil {
+		log.Println("ssh handshake failed:", err)
+		conn.Close()
+		return
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	for newChan := range chans {
+		if newChan.ChannelType() != "session" {
+			newChan.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChan.Accept()
+		if err != nil {
+			log.Println("error accepting ssh channel:", err)
+			continue
+		}
+		go l.handleSession(channel, requests, sshConn.RemoteAddr())
+	}
+}
+
+// Waits for the client to request a shell, then
+// hands the session over to Accept.
+func (l *sshListener) handleSession(channel ssh.Channel, requests <-chan *ssh.Request, addr net.Addr) {
+	conn := &sshConn{Channel: channel, addr: addr, done: make(chan struct{})}
+	started := false
+	for req := range requests {
+		ok := true
+		switch req.Type {
+		case "pty-req":
+			conn.lock.Lock()
+			conn.term.Type, conn.term.Width, conn.term.Height, ok = parsePtyRequest(req.Payload)
+			conn.lock.Unlock()
+		case "window-change":
+			width, height, valid := parseWindowChange(req.Payload)
+			if valid {
+				conn.resize(width, height)
+			}
+			ok = valid
+		case "env":
+			var env struct{ Name, Value string }
+			if ssh.Unmarshal(req.Payload, &env) == nil && env.Name == "COLORTERM" {
+				conn.lock.Lock()
+				conn.term.ColorTerm = env.Value
+				conn.lock.Unlock()
+			}
+		case "shell":
+			ok = !started
+			if ok {
+				started = true
+				go func() {
+					if l.push(conn) {
+						<-conn.done
+					}
+					channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+					channel.Close()
+				}()
+			}
+		default:
+			ok = false
+		}
+
+		if req.WantReply {
+			req.Reply(ok, nil)
+		}
+	}
+	conn.Close()
+}
+
+func parsePtyRequest(payload []byte) (string, uint16, uint16, bool) {
+	var req struct {
+		Term          string
+		Columns, Rows uint32
+		Width, Height uint32
+		Modes         string
+	}
+	if err := ssh.Unmarshal(payload, &req); err != nil {
+		return "", 0, 0, false
+	}
+	if req.Columns == 0 || req.Rows == 0 {
+		// The size is unknown, so the default is used.
+		return req.Term, 0, 0, true
+	}
+	width, height := clampSize(int(req.Columns), int(req.Rows))
+	return req.Term, width, height, true
+}
+
+func parseWindowChange(payload []byte) (uint16, uint16, bool) {
+	if len(payload) < 8 {
+		return 0, 0, false
+	}
+	width, height := clampSize(int(binary.BigEndian.Uint32(payload)), int(binary.BigEndian.Uint32(payload[4:])))
+	return width, height, true
+}
+
+// An SSH session with a PTY.
+type sshConn struct {
+	ssh.Channel
+	addr net.Addr
+	term Terminal
+
+	onResize func(uint16, uint16)
+	lock     sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *sshConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.Channel.Close()
+}
+
+func (c *sshConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func (c *sshConn) Terminal() Terminal {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.term
+}
+
+func (c *sshConn) OnResize(f func(width uint16, height uint16)) {
+	c.lock.Lock()
+	c.onResize = f
+	c.lock.Unlock()
+}
+
+func (c *sshConn) resize(width uint16, height uint16) {
+	c.lock.Lock()
+	c.term.Width, c.term.Height = width, height
+	f := c.onResize
+	c.lock.Unlock()
+
+	if f != nil {
+		f(width, height)
+	}
+}
+
+// Terminals send a carriage return for the
+// enter key, which widgets expect as '\n'.
+func (c *sshConn) Read(buf []byte) (int, error) {
+	n, err := c.Channel.Read(buf)
+	for i := 0; i < n; i++ {
+		if buf[i] == '\r' {
+			buf[i] = '\n'
+		}
+	}
+	return n, err
+}
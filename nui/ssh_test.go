@@ -0,0 +1,98 @@
+package nui_test
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/allen-b1/sus-tux/nui"
+	"github.com/allen-b1/sus-tux/nui/nuitest"
+	"golang.org/x/crypto/ssh"
+)
+
+// Waits until the client was sent text, then forgets everything
+// it was sent so far.
+func waitOutput(t *testing.T, out *closeBuffer, text string) {
+	t.Helper()
+	deadline := time.Now().Add(nuitest.DefaultTimeout)
+	for !out.contains(text) {
+		if time.Now().After(deadline) {
+			t.Fatalf("client was not sent %q", text)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	out.lock.Lock()
+	out.Reset()
+	out.lock.Unlock()
+}
+
+// Connects over SSH with terminal sizes that are empty or
+// too large to draw, which the server must survive.
+func TestSSHTerminalSize(t *testing.T) {
+	srv := nui.NewServer()
+	srv.HandleConnect = func(clientID int) {
+		srv.SetScreen(clientID, &nui.Screen{Widgets: []nui.Widget{
+			&nui.Label{Format: nui.Format{Fg: nui.White, Bg: nui.Black}, Text: "hello"},
+		}})
+	}
+	srv.HandleDisconnect = func(clientID int) {}
+
+	config, err := nui.NewSSHConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := nui.NewSSHListener(ln, config)
+	defer l.Close()
+	go srv.Serve(l)
+
+	client, err := ssh.Dial("tcp", ln.Addr().String(), &ssh.ClientConfig{
+		User:            "test",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	session, err := client.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	out := new(closeBuffer)
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Copies through Write, which locks the buffer.
+	go io.Copy(struct{ io.Writer }{out}, stdout)
+	// Without a pipe for input, the session would send
+	// EOF at once, which disconnects the client.
+	if _, err := session.StdinPipe(); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.RequestPty("xterm", 300, 300, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := session.Shell(); err != nil {
+		t.Fatal(err)
+	}
+	waitOutput(t, out, "hello")
+
+	// Every resize clears the terminal and draws it again.
+	for _, size := range [][2]int{{0, 0}, {70000, 300}, {1, 0}, {300, 300}} {
+		if err := session.WindowChange(size[0], size[1]); err != nil {
+			t.Fatal(err)
+		}
+		waitOutput(t, out, "\x1bc")
+	}
+	if err := session.WindowChange(24, 80); err != nil {
+		t.Fatal(err)
+	}
+	waitOutput(t, out, "hello")
+}
@@ -0,0 +1,382 @@
+package nui
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Type TextLine represents a line of a TextView.
+type TextLine struct {
+	Text string
+
+	// If set, used instead of the view's Format.
+	Format *Format
+}
+
+// Represents read-only text, such as a chat log, which is wrapped
+// at word boundaries. The view stays scrolled to the newest lines
+// unless it is scrolled back with the arrow keys, Page Up, Page Down,
+// Home or the mouse wheel; End scrolls to the newest lines again.
+type TextView struct {
+	X      uint16
+	Y      uint16
+	Width  uint16
+	Height uint16
+	Format Format
+
+	Lines []TextLine
+	// If non-zero, Append drops the oldest lines
+	// to keep at most this many lines.
+	MaxLines int
+
+	// Number of rows scrolled back from the newest one
+	scroll int
+}
+
+// Adds a line after the last one.
+func (v *TextView) Append(text string, format *Format) {
+	v.Lines = append(v.Lines, TextLine{Text: text, Format: format})
+	if v.MaxLines > 0 && len(v.Lines) > v.MaxLines {
+		v.Lines = v.Lines[len(v.Lines)-v.MaxLines:]
+	}
+}
+
+type textRow struct {
+	text   string
+	format Format
+}
+
+func (v *TextView) rows() []textRow {
+	var rows []textRow
+	for _, line := range v.Lines {
+		format := v.Format
+		if line.Format != nil {
+			format = *line.Format
+		}
+		for _, text := range wrap(line.Text, int(v.Width)) {
+			rows = append(rows, textRow{text, format})
+		}
+	}
+	return rows
+}
+
+func (v *TextView) Draw(buf *Buffer) {
+	rows := v.rows()
+	if v.scroll > len(rows)-int(v.Height) {
+		v.scroll = len(rows) - int(v.Height)
+	}
+	if v.scroll < 0 {
+		v.scroll = 0
+	}
+
+	first := len(rows) - int(v.Height) - v.scroll
+	for y := 0; y < int(v.Height); y++ {
+		if first+y < 0 {
+			drawRow(buf, v.X, v.Y+uint16(y), v.Width, "", v.Format)
+			continue
+		}
+		row := rows[first+y]
+		drawRow(buf, v.X, v.Y+uint16(y), v.Width, row.text, row.format)
+	}
+
+	buf.SetCursor(int(v.X), int(v.Y)+int(v.Height)-1, v.Format)
+}
+
+func (v *TextView) Size() (uint16, uint16) {
+	return v.Width, v.Height
+}
+
+func (v *TextView) Place(area Rect) {
+	v.X, v.Y = area.X, area.Y
+}
+
+func (v *TextView) Focus(focus bool) {}
+func (v *TextView) Keypress(ch byte) {}
+
+func (v *TextView) Key(key Key) {
+	switch key {
+	case KeyUp:
+		v.scroll++
+	case KeyDown:
+		v.scroll--
+	case KeyPageUp:
+		v.scroll += int(v.Height)
+	case KeyPageDown:
+		v.scroll -= int(v.Height)
+	case KeyHome:
+		v.scroll = len(v.rows())
+	case KeyEnd:
+		v.scroll = 0
+	}
+	if v.scroll < 0 {
+		v.scroll = 0
+	}
+}
+
+func (v *TextView) Mouse(ev MouseEvent) bool {
+	if !(Rect{v.X, v.Y, v.Width, v.Height}).Contains(int(ev.X), int(ev.Y)) || ev.Release {
+		return false
+	}
+	if ev.Button == MouseWheelUp {
+		v.Key(KeyUp)
+	} else if ev.Button == MouseWheelDown {
+		v.Key(KeyDown)
+	}
+	return true
+}
+
+// Splits text into rows at most width long, breaking
+// lines between words where possible.
+func wrap(text string, width int) []string {
+	if width < 1 {
+		return nil
+	}
+
+	var rows []string
+	for _, line := range strings.Split(text, "\n") {
+		row := ""
+		for _, word := range strings.Fields(line) {
+			if row != "" && len(row)+1+len(word) <= width {
+				row += " " + word
+				continue
+			}
+			if row != "" {
+				rows = append(rows, row)
+			}
+			for len(word) > width {
+				rows = append(rows, word[:width])
+				word = word[width:]
+			}
+			row = word
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Represents an editable text box with multiple lines. The
+// arrow keys, Home, End, backspace and Delete move the cursor
+// and edit text anywhere in it. Text is scrolled to keep the
+// cursor visible rather than wrapped.
+//
+// If HandleSubmit is set, enter submits the text, which is then
+// cleared and added to the history; the up and down arrow keys
+// recall earlier text from the first and last lines. Otherwise,
+// enter starts a new line.
+//
+// Note: When the event handlers are called,
+// the screen that this widget belongs to is write-locked.
+type TextArea struct {
+	X      uint16
+	Y      uint16
+	Width  uint16
+	Height uint16
+	Format Format
+
+	Text string
+	// If non-zero, the maximum length of Text
+	Max int
+	// If non-zero, the maximum number of texts in the history
+	MaxHistory int
+
+	HandleInput  func(text string)
+	HandleSubmit func(text string)
+
+	// Index in Text of the cursor
+	cursor int
+	// Position of the top-left visible character
+	scrollX, scrollY int
+
+	history []string
+	// Index in history of the recalled text, or len(history) if
+	// the text is new, in which case it is kept in draft.
+	recalled int
+	draft    string
+
+	focused bool
+}
+
+// Returns the line and column of an index in Text.
+func (a *TextArea) position(idx int) (int, int) {
+	line := strings.Count(a.Text[:idx], "\n")
+	return line, idx - (strings.LastIndex(a.Text[:idx], "\n") + 1)
+}
+
+// Returns the index in Text of a line and column,
+// moving it to the end of the line if it is shorter.
+func (a *TextArea) index(line int, col int) int {
+	lines := strings.Split(a.Text, "\n")
+	idx := 0
+	for i := 0; i < line; i++ {
+		idx += len(lines[i]) + 1
+	}
+	if col > len(lines[line]) {
+		col = len(lines[line])
+	}
+	return idx + col
+}
+
+func (a *TextArea) clampCursor() {
+	if a.cursor > len(a.Text) {
+		a.cursor = len(a.Text)
+	}
+	if a.cursor < 0 {
+		a.cursor = 0
+	}
+}
+
+func (a *TextArea) Draw(buf *Buffer) {
+	a.clampCursor()
+	line, col := a.position(a.cursor)
+	lines := strings.Split(a.Text, "\n")
+	a.scrollY = scrollTo(a.scrollY, line, len(lines), int(a.Height))
+	a.scrollX = scrollTo(a.scrollX, col, len(lines[line])+1, int(a.Width))
+	format := focusFormat(a.Format, a.focused)
+
+	for y := 0; y < int(a.Height); y++ {
+		text := ""
+		if a.scrollY+y < len(lines) {
+			text = lines[a.scrollY+y]
+		}
+		if a.scrollX < len(text) {
+			text = text[a.scrollX:]
+		} else {
+			text = ""
+		}
+		drawRow(buf, a.X, a.Y+uint16(y), a.Width, text, format)
+	}
+
+	buf.SetCursor(int(a.X)+col-a.scrollX, int(a.Y)+line-a.scrollY, format)
+}
+
+func (a *TextArea) Size() (uint16, uint16) {
+	return a.Width, a.Height
+}
+
+func (a *TextArea) Place(area Rect) {
+	a.X, a.Y = area.X, area.Y
+}
+
+func (a *TextArea) Focus(focus bool) {
+	a.focused = focus
+}
+
+func (a *TextArea) Keypress(ch byte) {
+	a.clampCursor()
+	if ch == '\b' || ch == 127 {
+		if a.cursor > 0 {
+			a.edit(a.cursor-1, a.cursor, "")
+		}
+	} else if ch == '\n' {
+		if a.HandleSubmit != nil {
+			a.submit()
+		} else {
+			a.edit(a.cursor, a.cursor, "\n")
+		}
+	} else if unicode.IsPrint(rune(ch)) {
+		a.edit(a.cursor, a.cursor, string(rune(ch)))
+	}
+}
+
+func (a *TextArea) Key(key Key) {
+	a.clampCursor()
+	line, col := a.position(a.cursor)
+	lines := strings.Count(a.Text, "\n") + 1
+
+	switch key {
+	case KeyLeft:
+		if a.cursor > 0 {
+			a.cursor--
+		}
+	case KeyRight:
+		if a.cursor < len(a.Text) {
+			a.cursor++
+		}
+	case KeyUp:
+		if line > 0 {
+			a.cursor = a.index(line-1, col)
+		} else if a.HandleSubmit != nil {
+			a.recall(a.recalled - 1)
+		}
+	case KeyDown:
+		if line < lines-1 {
+			a.cursor = a.index(line+1, col)
+		} else if a.HandleSubmit != nil {
+			a.recall(a.recalled + 1)
+		}
+	case KeyHome:
+		a.cursor = a.index(line, 0)
+	case KeyEnd:
+		a.cursor = a.index(line, len(a.Text))
+	case KeyDelete:
+		if a.cursor < len(a.Text) {
+			a.edit(a.cursor, a.cursor+1, "")
+		}
+	}
+}
+
+func (a *TextArea) Mouse(ev MouseEvent) bool {
+	if !(Rect{a.X, a.Y, a.Width, a.Height}).Contains(int(ev.X), int(ev.Y)) || ev.Release {
+		return false
+	}
+	if ev.Button == MouseLeft {
+		line := a.scrollY + int(ev.Y-a.Y)
+		if lines := strings.Count(a.Text, "\n") + 1; line >= lines {
+			line = lines - 1
+		}
+		a.cursor = a.index(line, a.scrollX+int(ev.X-a.X))
+	}
+	return true
+}
+
+// Replaces Text[start:end] with text, and moves
+// the cursor to the end of the new text.
+func (a *TextArea) edit(start int, end int, text string) {
+	if a.Max > 0 && len(a.Text)-(end-start)+len(text) > a.Max {
+		return
+	}
+	a.Text = a.Text[:start] + text + a.Text[end:]
+	a.cursor = start + len(text)
+	a.recalled = len(a.history)
+
+	if a.HandleInput != nil {
+		a.HandleInput(a.Text)
+	}
+}
+
+func (a *TextArea) submit() {
+	text := a.Text
+	if text != "" && (len(a.history) == 0 || a.history[len(a.history)-1] != text) {
+		a.history = append(a.history, text)
+		if a.MaxHistory > 0 && len(a.history) > a.MaxHistory {
+			a.history = a.history[len(a.history)-a.MaxHistory:]
+		}
+	}
+	a.Text, a.cursor, a.draft = "", 0, ""
+	a.recalled = len(a.history)
+
+	a.HandleSubmit(text)
+}
+
+// Replaces the text with the text at an index in the history,
+// or the text that was being written after the last one.
+func (a *TextArea) recall(idx int) {
+	if idx < 0 || idx > len(a.history) || idx == a.recalled {
+		return
+	}
+	if a.recalled == len(a.history) {
+		a.draft = a.Text
+	}
+
+	a.recalled = idx
+	if idx == len(a.history) {
+		a.Text = a.draft
+	} else {
+		a.Text = a.history[idx]
+	}
+	a.cursor = len(a.Text)
+
+	if a.HandleInput != nil {
+		a.HandleInput(a.Text)
+	}
+}
@@ -0,0 +1,428 @@
+// A small terminal for the browser client. It understands the escape
+// sequences that nui.Server sends, and sends keys and mouse events
+// the way xterm does.
+"use strict";
+
+const PALETTE = [
+	"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+	"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+];
+const CUBE_LEVELS = [0, 95, 135, 175, 215, 255];
+
+// Returns the CSS color of an index into the 256-color palette.
+function indexedColor(n) {
+	if (n < 16) {
+		return PALETTE[n];
+	}
+	if (n < 232) {
+		n -= 16;
+		return "rgb(" + CUBE_LEVELS[Math.floor(n / 36)] + "," + CUBE_LEVELS[Math.floor(n / 6) % 6] + "," + CUBE_LEVELS[n % 6] + ")";
+	}
+	const level = 8 + 10 * (n - 232);
+	return "rgb(" + level + "," + level + "," + level + ")";
+}
+
+const DEFAULT_PEN = { fg: null, bg: null, bold: false, dim: false, italic: false, underline: false, reverse: false };
+
+const KEYS = {
+	Enter: "\r", Backspace: "\x7f", Tab: "\t", Escape: "\x1b",
+	ArrowUp: "\x1b[A", ArrowDown: "\x1b[B", ArrowRight: "\x1b[C", ArrowLeft: "\x1b[D",
+	Home: "\x1b[H", End: "\x1b[F", Insert: "\x1b[2~", Delete: "\x1b[3~",
+	PageUp: "\x1b[5~", PageDown: "\x1b[6~",
+};
+
+class Terminal {
+	constructor(element) {
+		this.element = element;
+		this.decoder = new TextDecoder();
+		this.pending = "";
+		this.mouse = false;
+		this.cursorVisible = true;
+		this.onData = () => {};
+		this.onResize = () => {};
+
+		this.measure();
+		this.reset(this.fitCols(), this.fitRows());
+
+		element.tabIndex = 0;
+		element.addEventListener("keydown", (e) => this.keydown(e));
+		element.addEventListener("paste", (e) => {
+			e.preventDefault();
+			this.onData(e.clipboardData.getData("text").replace(/\r?\n/g, "\r"));
+		});
+		element.addEventListener("mousedown", (e) => this.mouseEvent(e, e.button, false));
+		element.addEventListener("mouseup", (e) => this.mouseEvent(e, e.button, true));
+		element.addEventListener("wheel", (e) => this.mouseEvent(e, e.deltaY < 0 ? 64 : 65, false), { passive: false });
+		element.addEventListener("contextmenu", (e) => {
+			if (this.mouse) {
+				e.preventDefault();
+			}
+		});
+	}
+
+	// Measures the size of a cell.
+	measure() {
+		const probe = document.createElement("span");
+		probe.textContent = "W".repeat(10);
+		this.element.appendChild(probe);
+		const rect = probe.getBoundingClientRect();
+		this.cellWidth = rect.width / 10;
+		this.cellHeight = rect.height;
+		this.element.removeChild(probe);
+	}
+
+	fitCols() {
+		return Math.max(1, Math.floor(this.element.clientWidth / this.cellWidth));
+	}
+
+	fitRows() {
+		return Math.max(1, Math.floor(this.element.clientHeight / this.cellHeight));
+	}
+
+	// Resizes the terminal to fill its element.
+	fit() {
+		const cols = this.fitCols(), rows = this.fitRows();
+		if (cols === this.cols && rows === this.rows) {
+			return;
+		}
+		const old = this.cells, oldCols = this.cols, oldRows = this.rows;
+		this.reset(cols, rows);
+		for (let y = 0; y < Math.min(rows, oldRows); y++) {
+			for (let x = 0; x < Math.min(cols, oldCols); x++) {
+				this.cells[y * cols + x] = old[y * oldCols + x];
+			}
+		}
+		this.redraw();
+		this.onResize(cols, rows);
+	}
+
+	reset(cols, rows) {
+		this.cols = cols;
+		this.rows = rows;
+		this.cells = new Array(cols * rows);
+		this.x = 0;
+		this.y = 0;
+		this.wrap = false;
+		this.pen = Object.assign({}, DEFAULT_PEN);
+		this.top = 0;
+		this.bottom = rows - 1;
+		this.erase(0, cols * rows);
+
+		this.element.textContent = "";
+		this.lines = [];
+		for (let y = 0; y < rows; y++) {
+			const line = document.createElement("div");
+			this.element.appendChild(line);
+			this.lines.push(line);
+		}
+		this.dirty = new Set();
+		this.redraw();
+	}
+
+	redraw() {
+		for (let y = 0; y < this.rows; y++) {
+			this.dirty.add(y);
+		}
+		this.scheduleRender();
+	}
+
+	scheduleRender() {
+		if (!this.frame) {
+			this.frame = requestAnimationFrame(() => this.render());
+		}
+	}
+
+	// Draws the rows that changed, as runs of cells with the same format.
+	render() {
+		this.frame = null;
+		for (const y of this.dirty) {
+			const line = this.lines[y];
+			line.textContent = "";
+			let run = "", runStyle = null;
+			const flush = () => {
+				if (run === "") {
+					return;
+				}
+				const span = document.createElement("span");
+				span.textContent = run;
+				span.style.cssText = runStyle;
+				line.appendChild(span);
+				run = "";
+			};
+			for (let x = 0; x < this.cols; x++) {
+				const cell = this.cells[y * this.cols + x];
+				const cursor = this.cursorVisible && x === this.x && y === this.y;
+				const style = this.style(cell.pen, cursor);
+				if (style !== runStyle) {
+					flush();
+					runStyle = style;
+				}
+				run += cell.ch;
+			}
+			flush();
+		}
+		this.dirty.clear();
+	}
+
+	// Returns the CSS of a cell's format.
+	style(pen, cursor) {
+		let fg = pen.fg || PALETTE[7], bg = pen.bg || PALETTE[0];
+		if (pen.reverse !== cursor) {
+			[fg, bg] = [bg, fg];
+		}
+		let css = "color:" + fg + ";background:" + bg;
+		if (pen.bold) css += ";font-weight:bold";
+		if (pen.dim) css += ";opacity:0.6";
+		if (pen.italic) css += ";font-style:italic";
+		if (pen.underline) css += ";text-decoration:underline";
+		return css;
+	}
+
+	// Interprets output sent to the terminal.
+	write(bytes) {
+		this.writeString(this.decoder.decode(bytes, { stream: true }));
+	}
+
+	writeString(text) {
+		const oldY = this.y;
+		const data = this.pending + text;
+		this.pending = "";
+		for (let i = 0; i < data.length; i++) {
+			if (data[i] !== "\x1b") {
+				this.control(data[i]);
+				continue;
+			}
+			const n = this.escape(data, i);
+			if (n === 0) {
+				this.pending = data.slice(i);
+				break;
+			}
+			i += n - 1;
+		}
+		this.dirty.add(oldY);
+		this.dirty.add(this.y);
+		this.scheduleRender();
+	}
+
+	control(c) {
+		if (c === "\r") {
+			this.x = 0;
+			this.wrap = false;
+		} else if (c === "\n") {
+			this.wrap = false;
+			this.lineFeed();
+		} else if (c === "\b") {
+			if (this.x > 0) {
+				this.x--;
+			}
+			this.wrap = false;
+		} else if (c >= " ") {
+			if (this.wrap) {
+				this.x = 0;
+				this.wrap = false;
+				this.lineFeed();
+			}
+			this.cells[this.y * this.cols + this.x] = { ch: c, pen: this.pen };
+			this.dirty.add(this.y);
+			if (this.x === this.cols - 1) {
+				this.wrap = true;
+			} else {
+				this.x++;
+			}
+		}
+	}
+
+	lineFeed() {
+		if (this.y === this.bottom) {
+			this.scroll(1);
+		} else if (this.y < this.rows - 1) {
+			this.y++;
+		}
+	}
+
+	// Sets cells from start to end to spaces with the current background.
+	erase(start, end) {
+		const pen = Object.assign({}, DEFAULT_PEN, { bg: this.pen.bg });
+		for (let i = start; i < end; i++) {
+			this.cells[i] = { ch: " ", pen: pen };
+		}
+		for (let y = Math.floor(start / this.cols); y * this.cols < end && y < this.rows; y++) {
+			if (this.dirty) {
+				this.dirty.add(y);
+			}
+		}
+	}
+
+	// Scrolls the scrolling region up by n lines, or down if n is negative.
+	scroll(n) {
+		const w = this.cols;
+		for (; n > 0; n--) {
+			this.cells.copyWithin(this.top * w, (this.top + 1) * w, (this.bottom + 1) * w);
+			this.erase(this.bottom * w, (this.bottom + 1) * w);
+		}
+		for (; n < 0; n++) {
+			this.cells.copyWithin((this.top + 1) * w, this.top * w, this.bottom * w);
+			this.erase(this.top * w, (this.top + 1) * w);
+		}
+		for (let y = this.top; y <= this.bottom; y++) {
+			this.dirty.add(y);
+		}
+	}
+
+	// Handles the escape sequence at data[i]. Returns its
+	// length, or 0 if data ends before the sequence does.
+	escape(data, i) {
+		if (i + 1 >= data.length) {
+			return 0;
+		}
+		if (data[i + 1] === "c") {
+			this.reset(this.cols, this.rows);
+			this.mouse = false;
+			this.cursorVisible = true;
+			return 2;
+		}
+		if (data[i + 1] !== "[") {
+			return 2;
+		}
+
+		let end = i + 2;
+		while (end < data.length && (data.charCodeAt(end) < 0x40 || data.charCodeAt(end) > 0x7e)) {
+			end++;
+		}
+		if (end === data.length) {
+			return 0;
+		}
+		this.csi(data.slice(i + 2, end), data[end]);
+		return end + 1 - i;
+	}
+
+	csi(params, final) {
+		if (params[0] === "?") {
+			const set = final === "h";
+			for (const mode of params.slice(1).split(";")) {
+				if (mode === "1000") {
+					this.mouse = set;
+				} else if (mode === "25") {
+					this.cursorVisible = set;
+					this.dirty.add(this.y);
+				}
+			}
+			return;
+		}
+
+		const ps = params.split(";");
+		const param = (i, def) => {
+			const n = parseInt(ps[i], 10);
+			return isNaN(n) ? def : n;
+		};
+		const clamp = (n, min, max) => Math.min(Math.max(n, min), max);
+		switch (final) {
+		case "A": this.y = clamp(this.y - param(0, 1), 0, this.rows - 1); break;
+		case "B": this.y = clamp(this.y + param(0, 1), 0, this.rows - 1); break;
+		case "C": this.x = clamp(this.x + param(0, 1), 0, this.cols - 1); break;
+		case "D": this.x = clamp(this.x - param(0, 1), 0, this.cols - 1); break;
+		case "H":
+			this.dirty.add(this.y);
+			this.y = clamp(param(0, 1) - 1, 0, this.rows - 1);
+			this.x = clamp(param(1, 1) - 1, 0, this.cols - 1);
+			break;
+		case "J": {
+			const pos = this.y * this.cols + this.x;
+			const mode = param(0, 0);
+			if (mode === 0) this.erase(pos, this.cells.length);
+			else if (mode === 1) this.erase(0, pos + 1);
+			else if (mode === 2) this.erase(0, this.cells.length);
+			break;
+		}
+		case "K": {
+			const start = this.y * this.cols;
+			const mode = param(0, 0);
+			if (mode === 0) this.erase(start + this.x, start + this.cols);
+			else if (mode === 1) this.erase(start, start + this.x + 1);
+			else if (mode === 2) this.erase(start, start + this.cols);
+			break;
+		}
+		case "r":
+			this.top = clamp(param(0, 1) - 1, 0, this.rows - 1);
+			this.bottom = clamp(param(1, this.rows) - 1, this.top, this.rows - 1);
+			this.dirty.add(this.y);
+			this.x = 0;
+			this.y = 0;
+			break;
+		case "S": this.scroll(param(0, 1)); break;
+		case "T": this.scroll(-param(0, 1)); break;
+		case "m": this.sgr(ps.map((p) => parseInt(p, 10) || 0)); break;
+		default: return;
+		}
+		this.wrap = false;
+	}
+
+	// Sets the format of the characters written after it.
+	sgr(ps) {
+		const pen = Object.assign({}, this.pen);
+		for (let i = 0; i < ps.length; i++) {
+			const n = ps[i];
+			if (n === 0) Object.assign(pen, DEFAULT_PEN);
+			else if (n === 1) pen.bold = true;
+			else if (n === 2) pen.dim = true;
+			else if (n === 3) pen.italic = true;
+			else if (n === 4) pen.underline = true;
+			else if (n === 7) pen.reverse = true;
+			else if (n === 22) pen.bold = pen.dim = false;
+			else if (n === 23) pen.italic = false;
+			else if (n === 24) pen.underline = false;
+			else if (n === 27) pen.reverse = false;
+			else if (n === 38 || n === 48) {
+				let color;
+				if (ps[i + 1] === 5) {
+					color = indexedColor(ps[i + 2] || 0);
+					i += 2;
+				} else {
+					color = "rgb(" + (ps[i + 2] || 0) + "," + (ps[i + 3] || 0) + "," + (ps[i + 4] || 0) + ")";
+					i += 4;
+				}
+				pen[n === 38 ? "fg" : "bg"] = color;
+			}
+			else if (n >= 30 && n <= 37) pen.fg = PALETTE[n - 30];
+			else if (n === 39) pen.fg = null;
+			else if (n >= 40 && n <= 47) pen.bg = PALETTE[n - 40];
+			else if (n === 49) pen.bg = null;
+			else if (n >= 90 && n <= 97) pen.fg = PALETTE[n - 90 + 8];
+			else if (n >= 100 && n <= 107) pen.bg = PALETTE[n - 100 + 8];
+		}
+		this.pen = pen;
+	}
+
+	keydown(e) {
+		let data = null;
+		if (e.key === "Tab" && e.shiftKey) {
+			data = "\x1b[Z";
+		} else if (KEYS[e.key] !== undefined) {
+			data = KEYS[e.key];
+		} else if (e.ctrlKey && !e.altKey && e.key.length === 1 && /[a-z]/i.test(e.key)) {
+			data = String.fromCharCode(e.key.toUpperCase().charCodeAt(0) - 64);
+		} else if (!e.ctrlKey && !e.metaKey && e.key.length === 1) {
+			data = e.key;
+		}
+		if (data !== null) {
+			e.preventDefault();
+			this.onData(data);
+		}
+	}
+
+	// Reports a mouse event with SGR coordinates, if the server asked for them.
+	mouseEvent(e, button, release) {
+		if (!this.mouse) {
+			return;
+		}
+		e.preventDefault();
+		this.element.focus();
+		const rect = this.element.getBoundingClientRect();
+		const x = Math.floor((e.clientX - rect.left) / this.cellWidth) + 1;
+		const y = Math.floor((e.clientY - rect.top) / this.cellHeight) + 1;
+		if (x < 1 || y < 1 || x > this.cols || y > this.rows) {
+			return;
+		}
+		this.onData("\x1b[<" + button + ";" + x + ";" + y + (release ? "m" : "M"));
+	}
+}
@@ -0,0 +1,197 @@
+package nui
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// The terminal page and its script, which
+// are served without any outside resources.
+//
+//go:embed web
+var webFiles embed.FS
+
+// Returns a listener that serves a terminal page over HTTP
+// from ln. The page connects back with a WebSocket, which is
+// accepted as a client.
+func NewWebListener(ln net.Listener) Listener {
+	l := &webListener{ln: ln, connQueue: newConnQueue()}
+
+	mux := http.NewServeMux()
+	files, _ := fs.Sub(webFiles, "web")
+	mux.Handle("/", http.FileServer(http.FS(files)))
+	mux.Handle("/ws", websocket.Handler(func(ws *websocket.Conn) {
+		// The connection is closed once this returns.
+		conn, err := newWebConn(ws)
+		if err != nil {
+			log.Println("rejected websocket:", err)
+			return
+		}
+		if l.push(conn) {
+			<-conn.done
+		}
+		conn.Close()
+	}))
+
+	l.srv = &http.Server{Handler: mux}
+	go func() {
+		err := l.srv.Serve(ln)
+		if err != http.ErrServerClosed {
+			log.Println("error serving http:", err)
+		}
+		l.close()
+	}()
+	return l
+}
+
+type webListener struct {
+	ln  net.Listener
+	srv *http.Server
+	*connQueue
+}
+
+func (l *webListener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+func (l *webListener) Close() error {
+	l.close()
+	return l.srv.Close()
+}
+
+// A message from the browser. Either Input
+// or the terminal size is set.
+type webMessage struct {
+	Input string `json:"input"`
+	Cols  int    `json:"cols"`
+	Rows  int    `json:"rows"`
+}
+
+// A WebSocket connection from the terminal page. Output is sent
+// as binary messages, and input is received as JSON messages.
+type webConn struct {
+	*websocket.Conn
+	input *io.PipeReader
+	term  Terminal
+
+	onResize func(uint16, uint16)
+	lock     sync.Mutex
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Returns the terminal size that the page asked for, which is
+// 0 by 0 for the default size if it did not ask for any.
+func parseWebSize(query url.Values) (uint16, uint16, error) {
+	if query.Get("cols") == "" && query.Get("rows") == "" {
+		return 0, 0, nil
+	}
+	cols, err := strconv.Atoi(query.Get("cols"))
+	if err != nil || cols < 1 {
+		return 0, 0, fmt.Errorf("invalid cols: %q", query.Get("cols"))
+	}
+	rows, err := strconv.Atoi(query.Get("rows"))
+	if err != nil || rows < 1 {
+		return 0, 0, fmt.Errorf("invalid rows: %q", query.Get("rows"))
+	}
+	width, height := clampSize(cols, rows)
+	return width, height, nil
+}
+
+func newWebConn(ws *websocket.Conn) (*webConn, error) {
+	ws.PayloadType = websocket.BinaryFrame
+
+	width, height, err := parseWebSize(ws.Request().URL.Query())
+	if err != nil {
+		return nil, err
+	}
+
+	r, w := io.Pipe()
+	c := &webConn{
+		Conn:  ws,
+		input: r,
+		term:  Terminal{Width: width, Height: height, Type: "xterm-256color", ColorTerm: "truecolor"},
+		done:  make(chan struct{}),
+	}
+	go c.receive(w)
+	return c, nil
+}
+
+func (c *webConn) receive(w *io.PipeWriter) {
+	for {
+		var msg webMessage
+		if err := websocket.JSON.Receive(c.Conn, &msg); err != nil {
+			if err != io.EOF {
+				log.Println("error reading from websocket:", err)
+			}
+			w.CloseWithError(err)
+			return
+		}
+
+		if msg.Cols > 0 && msg.Rows > 0 {
+			c.resize(clampSize(msg.Cols, msg.Rows))
+		}
+		if msg.Input != "" {
+			// Terminals send a carriage return for the
+			// enter key, which widgets expect as '\n'.
+			if _, err := io.WriteString(w, strings.ReplaceAll(msg.Input, "\r", "\n")); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *webConn) Read(buf []byte) (int, error) {
+	return c.input.Read(buf)
+}
+
+func (c *webConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	c.input.Close()
+	return c.Conn.Close()
+}
+
+// The address of the browser, rather than the
+// origin of the page like websocket.Conn returns.
+func (c *webConn) RemoteAddr() net.Addr {
+	addr, err := net.ResolveTCPAddr("tcp", c.Request().RemoteAddr)
+	if err != nil {
+		return c.Conn.RemoteAddr()
+	}
+	return addr
+}
+
+func (c *webConn) Terminal() Terminal {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.term
+}
+
+func (c *webConn) OnResize(f func(width uint16, height uint16)) {
+	c.lock.Lock()
+	c.onResize = f
+	c.lock.Unlock()
+}
+
+func (c *webConn) resize(width uint16, height uint16) {
+	c.lock.Lock()
+	c.term.Width, c.term.Height = width, height
+	f := c.onResize
+	c.lock.Unlock()
+
+	if f != nil {
+		f(width, height)
+	}
+}
@@ -0,0 +1,80 @@
+package nui_test
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/allen-b1/sus-tux/nui"
+	"golang.org/x/net/websocket"
+)
+
+// Serves a screen with a label over HTTP, and returns the address
+// of its WebSocket and the number of clients that connected.
+func serveWeb(t *testing.T) (string, *int32) {
+	var connected int32
+	srv := nui.NewServer()
+	srv.HandleConnect = func(clientID int) {
+		atomic.AddInt32(&connected, 1)
+		srv.SetScreen(clientID, &nui.Screen{Widgets: []nui.Widget{
+			&nui.Label{Format: nui.Format{Fg: nui.White, Bg: nui.Black}, Text: "hello"},
+		}})
+	}
+	srv.HandleDisconnect = func(clientID int) {}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := nui.NewWebListener(ln)
+	t.Cleanup(func() { l.Close() })
+	go srv.Serve(l)
+	return "ws://" + ln.Addr().String() + "/ws", &connected
+}
+
+func dialWeb(t *testing.T, url string) *websocket.Conn {
+	ws, err := websocket.Dial(url, "", "http://localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// Connects from the terminal page with sizes that are
+// empty or too large to draw, which the server must survive.
+func TestWebTerminalSize(t *testing.T) {
+	url, _ := serveWeb(t)
+	ws := dialWeb(t, url+"?cols=300&rows=300")
+	out := new(closeBuffer)
+	go io.Copy(struct{ io.Writer }{out}, ws)
+	waitOutput(t, out, "hello")
+
+	// Sizes of 0 are ignored, and the largest size
+	// is drawn, which clears the terminal.
+	for _, size := range [][2]int{{0, 0}, {70000, 300}, {65536, 65536}} {
+		if err := websocket.JSON.Send(ws, map[string]int{"cols": size[0], "rows": size[1]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitOutput(t, out, "\x1bc")
+	if err := websocket.JSON.Send(ws, map[string]int{"cols": 80, "rows": 24}); err != nil {
+		t.Fatal(err)
+	}
+	waitOutput(t, out, "hello")
+}
+
+// Rejects pages that ask for a size that is not a number.
+func TestWebInvalidSize(t *testing.T) {
+	url, connected := serveWeb(t)
+	for _, query := range []string{"?cols=abc&rows=24", "?cols=80&rows=-1", "?cols=80", "?cols=1.5&rows=24"} {
+		ws := dialWeb(t, url+query)
+		if data, err := io.ReadAll(ws); err != nil || len(data) != 0 {
+			t.Errorf("%s: got %q, %v; want the connection closed", query, data, err)
+		}
+	}
+	if n := atomic.LoadInt32(connected); n != 0 {
+		t.Errorf("%d clients connected", n)
+	}
+}
@@ -12,11 +12,15 @@ type Label struct {
 }
 
 func (l *Label) Draw(buf *Buffer) {
-	idx := buf.Index(l.X, l.Y)
-	for i, c := range []byte(l.Text) {
-		buf.Chars[idx+i] = c
-		buf.Formats[idx+i] = l.Format
-	}
+	buf.WriteString(int(l.X), int(l.Y), l.Text, l.Format)
+}
+
+func (l *Label) Size() (uint16, uint16) {
+	return uint16(len(l.Text)), 1
+}
+
+func (l *Label) Place(area Rect) {
+	l.X, l.Y = area.X, area.Y
 }
 
 // Represents an entry.
@@ -32,26 +36,32 @@ type Entry struct {
 
 	HandleInput func(text string)
 	HandleEnter func(text string)
+
+	focused bool
 }
 
 func (e *Entry) Draw(buf *Buffer) {
-	idx := buf.Index(e.X, e.Y)
-	for i := 0; i < e.Max; i++ {
-		c := byte(' ')
-		if i < len(e.Text) {
-			c = e.Text[i]
-		}
+	format := focusFormat(e.Format, e.focused)
+	buf.Fill(Rect{e.X, e.Y, uint16(e.Max), 1}, ' ', format)
+	buf.WriteString(int(e.X), int(e.Y), e.Text, format)
+	buf.SetCursor(int(e.X)+len(e.Text), int(e.Y), format)
+}
 
-		buf.Chars[idx+i] = c
-		buf.Formats[idx+i] = e.Format
-	}
+func (e *Entry) Size() (uint16, uint16) {
+	return uint16(e.Max), 1
+}
 
-	buf.CursorX = e.X + uint16(len(e.Text))
-	buf.CursorY = e.Y
-	buf.CursorFormat = e.Format
+func (e *Entry) Place(area Rect) {
+	e.X, e.Y = area.X, area.Y
 }
 
-func (e *Entry) Focus(focus bool) {}
+func (e *Entry) Focus(focus bool) {
+	e.focused = focus
+}
+
+func (e *Entry) Mouse(ev MouseEvent) bool {
+	return ev.Button == MouseLeft && !ev.Release && Rect{e.X, e.Y, uint16(e.Max), 1}.Contains(int(ev.X), int(ev.Y))
+}
 
 func (e *Entry) Keypress(ch byte) {
 	if ch == '\b' || ch == 127 {
@@ -84,38 +94,40 @@ type Button struct {
 	Text   string
 
 	HandleClick func()
+
+	focused bool
 }
 
 func (b *Button) Draw(buf *Buffer) {
-	for x := b.X; x < b.X+uint16(len(b.Text))+4; x++ {
-		idx := buf.Index(x, b.Y)
-		buf.Chars[idx] = ' '
-		buf.Formats[idx] = b.Format
-	}
+	format := focusFormat(b.Format, b.focused)
+	buf.Fill(Rect{b.X, b.Y, uint16(len(b.Text)) + 4, 3}, ' ', format)
+	buf.WriteString(int(b.X)+2, int(b.Y)+1, b.Text, format)
+	buf.SetCursor(int(b.X)+2+len(b.Text), int(b.Y)+1, format)
+}
 
-	for x := b.X; x < b.X+uint16(len(b.Text))+4; x++ {
-		idx := buf.Index(x, b.Y+1)
-		buf.Formats[idx] = b.Format
+func (b *Button) Size() (uint16, uint16) {
+	return uint16(len(b.Text)) + 4, 3
+}
 
-		if x < b.X+2 || x >= b.X+2+uint16(len(b.Text)) {
-			buf.Chars[idx] = ' '
-		} else {
-			buf.Chars[idx] = b.Text[int(x-b.X-2)]
-		}
-	}
+func (b *Button) Place(area Rect) {
+	b.X, b.Y = area.X, area.Y
+}
 
-	for x := b.X; x < b.X+uint16(len(b.Text))+4; x++ {
-		idx := buf.Index(x, b.Y+2)
-		buf.Chars[idx] = ' '
-		buf.Formats[idx] = b.Format
-	}
+func (b *Button) Focus(focus bool) {
+	b.focused = focus
+}
 
-	buf.CursorX = b.X + 2 + uint16(len(b.Text))
-	buf.CursorY = b.Y + 1
-	buf.CursorFormat = b.Format
+func (b *Button) Mouse(ev MouseEvent) bool {
+	w, h := b.Size()
+	if ev.Button != MouseLeft || ev.Release || !(Rect{b.X, b.Y, w, h}).Contains(int(ev.X), int(ev.Y)) {
+		return false
+	}
+	if b.HandleClick != nil {
+		b.HandleClick()
+	}
+	return true
 }
 
-func (b *Button) Focus(focus bool) {}
 func (b *Button) Keypress(ch byte) {
 	if ch == '\n' && b.HandleClick != nil {
 		b.HandleClick()
@@ -0,0 +1,48 @@
+package nui
+
+import (
+	"testing"
+)
+
+// Focusable widgets look different while they have focus.
+func TestFocusFormat(t *testing.T) {
+	format := Format{Fg: White, Bg: Black}
+	selected := Format{Fg: Black, Bg: LightWhite}
+	underlined := format
+	underlined.Underline = true
+	reversed := format
+	reversed.Reverse = true
+
+	tests := []struct {
+		name    string
+		widget  FocusableWidget
+		x, y    int
+		blurred Format
+		focused Format
+	}{
+		{"entry", &Entry{Format: format, Text: "name", Max: 8}, 0, 0, format, reversed},
+		{"text area", &TextArea{Format: format, Text: "text", Width: 8, Height: 2}, 0, 1, format, reversed},
+		{"list", &List{
+			Format: format, SelectedFormat: selected, Width: 8, Height: 2,
+			Items: []ListItem{{Text: "one"}, {Text: "two"}}, Selected: 1,
+		}, 0, 1, underlined, selected},
+		{"table", &Table{
+			Format: format, SelectedFormat: selected, HeaderFormat: format, Height: 3,
+			Columns: []Column{{Title: "col"}}, Rows: [][]string{{"one"}, {"two"}},
+		}, 0, 1, underlined, selected},
+	}
+	for _, test := range tests {
+		for _, focus := range []bool{false, true} {
+			test.widget.Focus(focus)
+			buf := emptyBuffer(10, 4, Black)
+			test.widget.Draw(buf)
+			want := test.blurred
+			if focus {
+				want = test.focused
+			}
+			if got := buf.Formats[buf.Index(uint16(test.x), uint16(test.y))]; got != want {
+				t.Errorf("%s with focus %v: format is %+v, want %+v", test.name, focus, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package nui
+
+import (
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Draws frames and writes them to a single connection from its own
+// goroutine, so that a slow client never blocks the rest of the server.
+// Frames are only drawn after the client is invalidated, and any
+// invalidations that happen while a frame is being written are
+// coalesced into drawing the newest state once.
+type writer struct {
+	conn    Conn
+	timeout time.Duration
+
+	// Renders the client's current screen
+	// with the given terminal size.
+	render func(width uint16, height uint16) (*Buffer, ColorMode)
+
+	// Size of the client's terminal.
+	// Guarded by sizeLock.
+	width, height uint16
+	sizeLock      sync.Mutex
+
+	dirty chan struct{}
+	quit  chan struct{}
+	done  chan struct{}
+
+	finishing  chan struct{}
+	finishOnce sync.Once
+
+	// If set, everything written is also recorded.
+	record *Recorder
+}
+
+func newWriter(conn Conn, timeout time.Duration, width uint16, height uint16, render func(uint16, uint16) (*Buffer, ColorMode)) *writer {
+	return &writer{
+		conn:    conn,
+		timeout: timeout,
+		render:  render,
+		width:   width,
+		height:  height,
+		dirty:   make(chan struct{}, 1),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+
+		finishing: make(chan struct{}),
+	}
+}
+
+// Schedules a redraw. Never blocks.
+func (w *writer) invalidate() {
+	select {
+	case w.dirty <- struct{}{}:
+	default:
+	}
+}
+
+// Changes the size of the terminal and redraws it.
+func (w *writer) resize(width uint16, height uint16) {
+	width, height = clampSize(int(width), int(height))
+	w.sizeLock.Lock()
+	w.width, w.height = width, height
+	w.sizeLock.Unlock()
+	if w.record != nil {
+		w.record.Resize(width, height)
+	}
+	w.invalidate()
+}
+
+func (w *writer) size() (uint16, uint16) {
+	w.sizeLock.Lock()
+	defer w.sizeLock.Unlock()
+	return w.width, w.height
+}
+
+// Draws one last frame, restores the terminal to its normal
+// state and closes the connection. Never blocks.
+func (w *writer) finish() {
+	w.finishOnce.Do(func() { close(w.finishing) })
+}
+
+// Stops the writer, waiting for any frame
+// being written to finish.
+func (w *writer) close() {
+	close(w.quit)
+	<-w.done
+}
+
+func (w *writer) write(msg string) bool {
+	if msg == "" {
+		// Nothing changed since the last frame
+		return true
+	}
+	if conn, ok := w.conn.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	} else {
+		timer := time.AfterFunc(w.timeout, func() { w.conn.Close() })
+		defer timer.Stop()
+	}
+	_, err := io.WriteString(w.conn, msg)
+	if err != nil {
+		log.Println("error writing to client:", err)
+		// Closing the connection stops the client's read loop,
+		// which disconnects it.
+		w.conn.Close()
+		return false
+	}
+	if w.record != nil {
+		w.record.Output(msg)
+	}
+	return true
+}
+
+func (w *writer) run() {
+	defer close(w.done)
+
+	msg := new(strings.Builder)
+	clear(msg)
+	if !w.write(msg.String()) {
+		return
+	}
+
+	width, height := w.size()
+	sent := emptyBuffer(width, height, Default)
+	var mode ColorMode
+	first := true
+	for {
+		finished := false
+		select {
+		case <-w.dirty:
+		case <-w.finishing:
+			finished = true
+		case <-w.quit:
+			return
+		}
+
+		width, height := w.size()
+		buf, newMode := w.render(width, height)
+		msg := new(strings.Builder)
+		if (!first && newMode != mode) || width != sent.Width || len(buf.Chars) != len(sent.Chars) {
+			// Colors are downgraded while writing, so the
+			// previous buffer no longer matches the terminal.
+			// The same happens if the terminal was resized.
+			clear(msg)
+			sent = emptyBuffer(width, height, Default)
+		}
+		mode, first = newMode, false
+
+		writeDiff(msg, sent, buf, mode)
+		if finished {
+			restore(msg, height)
+			w.write(msg.String())
+			w.conn.Close()
+			return
+		}
+		if !w.write(msg.String()) {
+			return
+		}
+		sent = buf
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/allen-b1/sus-tux/nui"
+)
+
+// Shows another player's name in the host's lobby.
+// When focused, the host can kick ('k') or ban ('b')
+// the player.
+type PlayerWidget struct {
+	nui.Label
+
+	// Required
+	KickHandler func()
+	BanHandler  func()
+}
+
+func (p *PlayerWidget) Draw(buf *nui.Buffer) {
+	p.Label.Draw(buf)
+
+	buf.SetCursor(int(p.X)+len(p.Text), int(p.Y), p.Format)
+}
+
+func (p *PlayerWidget) Focus(focus bool) {}
+
+func (p *PlayerWidget) Keypress(ch byte) {
+	if ch == 'k' {
+		p.KickHandler()
+	} else if ch == 'b' {
+		p.BanHandler()
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Replays are gzipped, and start with this and a version byte.
+// Version 1 had only the move, kill and leave commands.
+const REPLAY_MAGIC = "SUSREPLAY"
+const REPLAY_VERSION = 2
+
+// Number of ticks after which a replay that is being
+// written is flushed, so that little is lost if the
+// server stops without closing it.
+const REPLAY_FLUSH_TICKS = 200
+
+// Maps by the name that replays refer to them with.
+var maps = map[string]*Map{}
+
+// Everything needed to reproduce a game: its initial configuration
+// and the commands of every tick, in order.
+type Replay struct {
+	Seed    int64
+	Map     string
+	Players []string
+
+	Ticks [][]Command
+}
+
+// Creates a game in its initial state.
+func (r *Replay) NewGame() (*Game, error) {
+	map_, ok := maps[r.Map]
+	if !ok {
+		return nil, fmt.Errorf("unknown map %q", r.Map)
+	}
+	return NewGame(len(r.Players), map_, r.Seed), nil
+}
+
+// Writes a replay as the game is played.
+type ReplayWriter struct {
+	f    io.WriteCloser
+	gz   *gzip.Writer
+	w    *bufio.Writer
+	tick uint
+}
+
+// Writes the configuration of a replay, whose ticks are
+// ignored. The writer closes w when it is closed.
+func NewReplayWriter(w io.WriteCloser, replay *Replay) (*ReplayWriter, error) {
+	gz := gzip.NewWriter(w)
+	r := &ReplayWriter{f: w, gz: gz, w: bufio.NewWriter(gz)}
+
+	r.w.WriteString(REPLAY_MAGIC)
+	r.w.WriteByte(REPLAY_VERSION)
+	r.writeVarint(replay.Seed)
+	r.writeString(replay.Map)
+	r.writeUvarint(uint64(len(replay.Players)))
+	for _, name := range replay.Players {
+		r.writeString(name)
+	}
+	if err := r.flush(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *ReplayWriter) writeUvarint(n uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	r.w.Write(buf[:binary.PutUvarint(buf[:], n)])
+}
+
+func (r *ReplayWriter) writeVarint(n int64) {
+	var buf [binary.MaxVarintLen64]byte
+	r.w.Write(buf[:binary.PutVarint(buf[:], n)])
+}
+
+func (r *ReplayWriter) writeString(s string) {
+	r.writeUvarint(uint64(len(s)))
+	r.w.WriteString(s)
+}
+
+func (r *ReplayWriter) flush() error {
+	if err := r.w.Flush(); err != nil {
+		return err
+	}
+	return r.gz.Flush()
+}
+
+// Writes the commands of the next tick.
+func (r *ReplayWriter) WriteTick(commands []Command) error {
+	r.writeUvarint(uint64(len(commands)))
+	for _, cmd := range commands {
+		r.writeUvarint(uint64(cmd.Player))
+		r.w.WriteByte(byte(cmd.Type))
+		if cmd.Type == CMD_MOVE {
+			// Both directions fit into one byte
+			r.w.WriteByte(byte((cmd.Direction[0]+1)*3 + cmd.Direction[1] + 1))
+		} else if cmd.Type == CMD_VOTE {
+			r.writeVarint(int64(cmd.Target))
+		}
+	}
+
+	r.tick++
+	if r.tick%REPLAY_FLUSH_TICKS == 0 {
+		return r.flush()
+	}
+	return nil
+}
+
+func (r *ReplayWriter) Close() error {
+	err := r.w.Flush()
+	if err == nil {
+		err = r.gz.Close()
+	}
+	if closeErr := r.f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+type replayReader struct {
+	*bufio.Reader
+}
+
+func (r replayReader) readString() (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if n > 1<<16 {
+		return "", errors.New("string is too long")
+	}
+	buf := make([]byte, n)
+	_, err = io.ReadFull(r, buf)
+	return string(buf), err
+}
+
+// Reads a replay. If it was cut off, such as when the server
+// stopped while it was being written, it ends at the last tick
+// that was written completely.
+func ReadReplay(f io.Reader) (*Replay, error) {
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	r := replayReader{bufio.NewReader(gz)}
+
+	magic := make([]byte, len(REPLAY_MAGIC)+1)
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic[:len(REPLAY_MAGIC)]) != REPLAY_MAGIC {
+		return nil, errors.New("not a replay")
+	}
+	if magic[len(REPLAY_MAGIC)] != REPLAY_VERSION {
+		return nil, fmt.Errorf("unsupported replay version %d", magic[len(REPLAY_MAGIC)])
+	}
+
+	replay := new(Replay)
+	if replay.Seed, err = binary.ReadVarint(r); err != nil {
+		return nil, err
+	}
+	if replay.Map, err = r.readString(); err != nil {
+		return nil, err
+	}
+	nplayers, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if nplayers == 0 || nplayers > MAX_PLAYERS {
+		return nil, fmt.Errorf("invalid number of players %d", nplayers)
+	}
+	for i := uint64(0); i < nplayers; i++ {
+		name, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		replay.Players = append(replay.Players, name)
+	}
+
+	for {
+		commands, err := readTick(r)
+		if err == io.EOF {
+			return replay, nil
+		} else if err == io.ErrUnexpectedEOF {
+			// Cut off
+			return replay, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("tick %d: %w", len(replay.Ticks), err)
+		}
+		replay.Ticks = append(replay.Ticks, commands)
+	}
+}
+
+// Returns io.EOF only if there are no more ticks.
+func readTick(r replayReader) ([]Command, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var commands []Command
+	for i := uint64(0); i < n; i++ {
+		player, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, unexpected(err)
+		}
+		typ, err := r.ReadByte()
+		if err != nil {
+			return nil, unexpected(err)
+		}
+
+		cmd := Command{Player: int(player), Type: CommandType(typ)}
+		switch cmd.Type {
+		case CMD_MOVE:
+			dir, err := r.ReadByte()
+			if err != nil {
+				return nil, unexpected(err)
+			}
+			if dir > 8 {
+				return nil, fmt.Errorf("invalid direction %d", dir)
+			}
+			cmd.Direction = [2]int8{int8(dir/3) - 1, int8(dir%3) - 1}
+		case CMD_VOTE:
+			target, err := binary.ReadVarint(r)
+			if err != nil {
+				return nil, unexpected(err)
+			}
+			cmd.Target = int(target)
+		case CMD_KILL, CMD_LEAVE, CMD_REPORT, CMD_VENT, CMD_TASK:
+		default:
+			return nil, fmt.Errorf("unknown command %d", typ)
+		}
+		commands = append(commands, cmd)
+	}
+	return commands, nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
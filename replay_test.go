@@ -0,0 +1,268 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/allen-b1/sus-tux/nui"
+)
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// Plays a game with bots while writing a replay of it. Returns the
+// replay, what was written of it and the game at its end.
+func writeTestReplay(t *testing.T, seed int64) (*Replay, []byte, *Game) {
+	t.Helper()
+	replay := &Replay{Seed: seed, Map: "research_facility"}
+	for i := 0; i < MAX_PLAYERS; i++ {
+		replay.Players = append(replay.Players, string(rune('a'+i)))
+	}
+	g, err := replay.NewGame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nav := newNavigator(g.Map)
+	var bots []*Bot
+	for i := range g.Players {
+		bot := NewBot(g, i, nav)
+		g.Subscribe(bot.HandleEvent)
+		bots = append(bots, bot)
+	}
+
+	out := new(bytes.Buffer)
+	w, err := NewReplayWriter(nopCloser{out}, replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for g.Phase != PHASE_OVER {
+		if g.Tick > 20000 {
+			t.Fatal("game did not end")
+		}
+		var commands []Command
+		for _, bot := range bots {
+			commands = append(commands, bot.Think(g)...)
+		}
+		g.Step(commands)
+		if err := w.WriteTick(commands); err != nil {
+			t.Fatal(err)
+		}
+		replay.Ticks = append(replay.Ticks, commands)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return replay, out.Bytes(), g
+}
+
+// Replays the whole replay on a new game.
+func playReplay(t *testing.T, replay *Replay, ticks int) *Game {
+	t.Helper()
+	g, err := replay.NewGame()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, commands := range replay.Ticks[:ticks] {
+		g.Step(commands)
+	}
+	return g
+}
+
+func TestReplayRoundTrip(t *testing.T) {
+	replay, data, played := writeTestReplay(t, 1)
+	read, err := ReadReplay(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, replay) {
+		t.Fatalf("read %+v, want %+v", read, replay)
+	}
+
+	replayed := playReplay(t, read, len(read.Ticks))
+	if replayed.Phase != PHASE_OVER {
+		t.Error("replayed game is not over")
+	}
+	if !reflect.DeepEqual(replayed.Snapshot(), played.Snapshot()) {
+		t.Errorf("replayed game ends as %+v, want %+v", replayed.Snapshot(), played.Snapshot())
+	}
+}
+
+// Writes every kind of command, with every direction.
+func TestReplayCommands(t *testing.T) {
+	replay := &Replay{Seed: -5, Map: "research_facility", Players: []string{"", "someone"}}
+	for x := int8(-1); x <= 1; x++ {
+		for y := int8(-1); y <= 1; y++ {
+			replay.Ticks = append(replay.Ticks, []Command{{Player: 1, Type: CMD_MOVE, Direction: [2]int8{x, y}}})
+		}
+	}
+	replay.Ticks = append(replay.Ticks, nil, []Command{
+		{Player: 0, Type: CMD_KILL},
+		{Player: 1, Type: CMD_REPORT},
+		{Player: 0, Type: CMD_VOTE, Target: 1},
+		{Player: 1, Type: CMD_VOTE, Target: SKIP_VOTE},
+		{Player: 0, Type: CMD_VENT},
+		{Player: 1, Type: CMD_TASK},
+		{Player: 1, Type: CMD_LEAVE},
+	})
+
+	out := new(bytes.Buffer)
+	w, err := NewReplayWriter(nopCloser{out}, replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, commands := range replay.Ticks {
+		w.WriteTick(commands)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadReplay(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, replay) {
+		t.Errorf("read %+v, want %+v", read, replay)
+	}
+}
+
+// Replays that were cut off end at the last tick that is complete.
+func TestReplayTruncated(t *testing.T) {
+	replay, data, _ := writeTestReplay(t, 2)
+	for _, n := range []int{len(data) / 4, len(data) / 2, len(data) - 10} {
+		read, err := ReadReplay(bytes.NewReader(data[:n]))
+		if err != nil {
+			t.Errorf("%d of %d bytes: %v", n, len(data), err)
+			continue
+		}
+		if len(read.Ticks) >= len(replay.Ticks) {
+			t.Errorf("%d of %d bytes: read all %d ticks", n, len(data), len(read.Ticks))
+		}
+		if !reflect.DeepEqual(read.Ticks, replay.Ticks[:len(read.Ticks)]) {
+			t.Errorf("%d of %d bytes: ticks differ", n, len(data))
+		}
+	}
+}
+
+func gzipped(data string) []byte {
+	out := new(bytes.Buffer)
+	gz := gzip.NewWriter(out)
+	gz.Write([]byte(data))
+	gz.Close()
+	return out.Bytes()
+}
+
+func TestReplayCorrupt(t *testing.T) {
+	// Seed 0, the map "m" and one player named "p"
+	version := string([]byte{REPLAY_VERSION})
+	header := REPLAY_MAGIC + version + "\x00" + "\x01m" + "\x01" + "\x01p"
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not gzipped", []byte(header)},
+		{"not a replay", gzipped("SUSPICIOUS" + version)},
+		{"header cut off", gzipped(header[:len(header)-1])},
+		{"newer version", gzipped(REPLAY_MAGIC + string([]byte{REPLAY_VERSION + 1}) + "\x00\x01m\x01\x01p")},
+		{"no players", gzipped(REPLAY_MAGIC + version + "\x00\x01m\x00")},
+		{"too many players", gzipped(REPLAY_MAGIC + version + "\x00\x01m\x7f")},
+		{"long name", gzipped(REPLAY_MAGIC + version + "\x00\xff\xff\xff\x0f")},
+		{"unknown command", gzipped(header + "\x01\x00\x63")},
+		{"invalid direction", gzipped(header + "\x01\x00\x00\x09")},
+	}
+	for _, test := range tests {
+		if replay, err := ReadReplay(bytes.NewReader(test.data)); err == nil {
+			t.Errorf("%s: read %+v", test.name, replay)
+		}
+	}
+}
+
+func TestReplayViewerSeek(t *testing.T) {
+	replay, _, played := writeTestReplay(t, 3)
+	v, err := NewReplayViewer(replay, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v.Key(nui.KeyEnd)
+	if !reflect.DeepEqual(v.game.Snapshot(), played.Snapshot()) {
+		t.Errorf("game at the end is %+v, want %+v", v.game.Snapshot(), played.Snapshot())
+	}
+
+	// Seeking back replays the game from the start.
+	v.Key(nui.KeyLeft)
+	want := playReplay(t, replay, len(replay.Ticks)-REPLAY_SEEK_TICKS)
+	if !reflect.DeepEqual(v.game.Snapshot(), want.Snapshot()) {
+		t.Errorf("game after seeking back is at tick %d, want %d", v.game.Tick, want.Tick)
+	}
+	v.Keypress(',')
+	if v.playing || int(v.game.Tick) != len(replay.Ticks)-REPLAY_SEEK_TICKS-1 {
+		t.Errorf("stepping back went to tick %d, playing is %v", v.game.Tick, v.playing)
+	}
+	v.Key(nui.KeyHome)
+	v.Keypress('.')
+	if v.game.Tick != 1 {
+		t.Errorf("stepping forward from the start went to tick %d", v.game.Tick)
+	}
+
+	// Playback stops at the end, and starts over from there.
+	v.Key(nui.KeyEnd)
+	v.Keypress(' ')
+	if !v.playing || v.game.Tick != 0 {
+		t.Errorf("playing again is at tick %d, playing is %v", v.game.Tick, v.playing)
+	}
+	for v.Advance() {
+	}
+	if int(v.game.Tick) != len(replay.Ticks) {
+		t.Errorf("playback stopped at tick %d of %d", v.game.Tick, len(replay.Ticks))
+	}
+}
+
+func TestReplayViewerPerspective(t *testing.T) {
+	replay, _, _ := writeTestReplay(t, 1)
+	v, err := NewReplayViewer(replay, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		key         byte
+		perspective int
+	}{
+		{'n', 0},
+		{'n', 1},
+		{'p', 0},
+		{'p', -1},
+		{'p', MAX_PLAYERS - 1},
+		{'n', -1},
+		{'n', 0},
+		{'o', -1},
+	}
+	for _, step := range steps {
+		v.Keypress(step.key)
+		if v.perspective != step.perspective {
+			t.Fatalf("after %q, perspective is %d, want %d", step.key, v.perspective, step.perspective)
+		}
+	}
+
+	// Only everyone's view can be moved.
+	x := v.centerX
+	v.Keypress('d')
+	if v.centerX != x+2*REPLAY_PAN {
+		t.Errorf("view moved from %d to %d", x, v.centerX)
+	}
+	v.Keypress('n')
+	v.Keypress('a')
+	if v.centerX != x+2*REPLAY_PAN {
+		t.Errorf("view moved while showing a player")
+	}
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/allen-b1/sus-tux/nui"
+)
+
+// Playback speeds, in game ticks per tick of real time.
+var replaySpeeds = []float64{0.25, 0.5, 1, 2, 4, 8}
+
+// Number of ticks that the arrow keys seek by.
+const REPLAY_SEEK_TICKS = 100
+
+// Number of cells that the map is moved by
+// while everyone's view is shown.
+const REPLAY_PAN = 4
+
+// Plays back a replay. While paused, the replay can be stepped
+// through a tick at a time, and any player's view can be shown,
+// as well as everyone's view, in which impostors are marked.
+type ReplayViewer struct {
+	Replay *Replay
+	// Name of the replay that is shown in the header
+	Name string
+
+	game *Game
+	// Tick that playback has reached, including
+	// fractions of a tick at slow speeds.
+	position float64
+	playing  bool
+	speed    int
+
+	// Index of the player whose view is shown,
+	// or -1 for everyone's view.
+	perspective int
+	// Center of everyone's view.
+	centerX, centerY uint32
+}
+
+func NewReplayViewer(replay *Replay, name string) (*ReplayViewer, error) {
+	game, err := replay.NewGame()
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayViewer{
+		Replay:      replay,
+		Name:        name,
+		game:        game,
+		playing:     true,
+		speed:       2,
+		perspective: -1,
+		centerX:     game.Map.Width / 2,
+		centerY:     game.Map.Height() / 2,
+	}, nil
+}
+
+// Advances playback by one tick of real time.
+// Returns false if nothing changed.
+func (v *ReplayViewer) Advance() bool {
+	if !v.playing {
+		return false
+	}
+	v.position += replaySpeeds[v.speed]
+	if v.position >= float64(len(v.Replay.Ticks)) {
+		v.position = float64(len(v.Replay.Ticks))
+		v.playing = false
+	}
+	v.stepTo(int(v.position))
+	return true
+}
+
+// Moves playback to a tick.
+func (v *ReplayViewer) seek(tick int) {
+	if tick < 0 {
+		tick = 0
+	}
+	if tick > len(v.Replay.Ticks) {
+		tick = len(v.Replay.Ticks)
+	}
+	v.position = float64(tick)
+	v.stepTo(tick)
+}
+
+// Steps the game to a tick, replaying it
+// from the start to go backwards.
+func (v *ReplayViewer) stepTo(tick int) {
+	if tick < int(v.game.Tick) {
+		// The replay was already checked when the viewer was created.
+		v.game, _ = v.Replay.NewGame()
+	}
+	for int(v.game.Tick) < tick {
+		v.game.Step(v.Replay.Ticks[v.game.Tick])
+	}
+}
+
+func formatTicks(ticks int) string {
+	d := time.Duration(ticks) * TICK_DURATION
+	return fmt.Sprintf("%02d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+func (v *ReplayViewer) Draw(buf *nui.Buffer) {
+	text := nui.Format{Fg: nui.White, Bg: nui.Black}
+	status := ternaryString(v.playing, "Playing", "Paused")
+	if v.game.Phase == PHASE_MEETING {
+		status += ", meeting"
+	} else if v.game.Phase == PHASE_OVER {
+		status += ", game over"
+	}
+	(&nui.Label{X: 2, Y: 0, Format: text, Text: fmt.Sprintf(
+		"Replay %s   %s at %gx   tick %d/%d",
+		v.Name, status, replaySpeeds[v.speed], v.game.Tick, len(v.Replay.Ticks),
+	)}).Draw(buf)
+
+	var mapWidget MapWidget
+	if v.perspective < 0 {
+		(&nui.Label{X: 2, Y: 1, Format: nui.Format{Fg: nui.LightWhite, Bg: nui.Black, Bold: true}, Text: "Everyone"}).Draw(buf)
+		mapWidget = MapWidget{CenterX: v.centerX, CenterY: v.centerY}
+	} else {
+		player := &v.game.Players[v.perspective]
+		name := displayName(v.Replay.Players[v.perspective], v.perspective)
+		(&nui.Label{X: 2, Y: 1, Format: nui.Format{Fg: playerColor(v.perspective), Bg: nui.Black, Bold: true}, Text: name}).Draw(buf)
+		(&nui.Label{
+			X: 3 + uint16(len(name)), Y: 1,
+			Format: nui.Format{Fg: ternaryColor(player.Imposter, nui.LightRed, nui.LightBlue), Bg: nui.Black},
+			Text:   ternaryString(player.Imposter, "Impostor", "Crewmate") + ternaryString(player.Dead, ", dead", ""),
+		}).Draw(buf)
+		mapWidget = MapWidget{PlayerColor: playerColor(v.perspective), Player: player}
+	}
+
+	(&nui.ProgressBar{
+		X: 2, Y: 2, Width: MAP_WIDTH - 4,
+		Text:   formatTicks(int(v.game.Tick)) + " / " + formatTicks(len(v.Replay.Ticks)),
+		Value:  int(v.game.Tick),
+		Max:    len(v.Replay.Ticks),
+		Format: nui.Format{Fg: nui.Black, Bg: nui.LightBlue}, EmptyFormat: nui.Format{Fg: nui.White, Bg: nui.LightBlack},
+	}).Draw(buf)
+	(&nui.Label{
+		X: 2, Y: 3, Format: nui.Format{Fg: nui.LightBlack, Bg: nui.Black},
+		Text: "space pause  left/right seek  , . step  - + speed  n p player  o everyone  wasd move view",
+	}).Draw(buf)
+
+	mapWidget.Y = 4
+	mapWidget.Map = v.game.Map
+	mapWidget.Players = v.game.Players
+	mapWidget.Draw(buf)
+}
+
+func (v *ReplayViewer) Focus(focus bool) {}
+
+func (v *ReplayViewer) Keypress(ch byte) {
+	if ch == ' ' {
+		if !v.playing && int(v.game.Tick) >= len(v.Replay.Ticks) {
+			v.seek(0)
+		}
+		v.playing = !v.playing
+	} else if ch == ',' {
+		v.playing = false
+		v.seek(int(v.game.Tick) - 1)
+	} else if ch == '.' {
+		v.playing = false
+		v.seek(int(v.game.Tick) + 1)
+	} else if ch == '-' && v.speed > 0 {
+		v.speed--
+	} else if (ch == '+' || ch == '=') && v.speed < len(replaySpeeds)-1 {
+		v.speed++
+	} else if ch == 'n' {
+		v.perspective++
+		if v.perspective >= len(v.game.Players) {
+			v.perspective = -1
+		}
+	} else if ch == 'p' {
+		v.perspective--
+		if v.perspective < -1 {
+			v.perspective = len(v.game.Players) - 1
+		}
+	} else if ch == 'o' {
+		v.perspective = -1
+	} else if v.perspective < 0 {
+		v.pan(ch)
+	}
+}
+
+// Moves everyone's view.
+func (v *ReplayViewer) pan(ch byte) {
+	x, y := int(v.centerX), int(v.centerY)
+	if ch == 'w' {
+		y -= REPLAY_PAN
+	} else if ch == 's' {
+		y += REPLAY_PAN
+	} else if ch == 'a' {
+		x -= 2 * REPLAY_PAN
+	} else if ch == 'd' {
+		x += 2 * REPLAY_PAN
+	}
+	if x >= 0 && x < int(v.game.Map.Width) {
+		v.centerX = uint32(x)
+	}
+	if y >= 0 && y < int(v.game.Map.Height()) {
+		v.centerY = uint32(y)
+	}
+}
+
+func (v *ReplayViewer) Key(key nui.Key) {
+	if key == nui.KeyLeft {
+		v.seek(int(v.game.Tick) - REPLAY_SEEK_TICKS)
+	} else if key == nui.KeyRight {
+		v.seek(int(v.game.Tick) + REPLAY_SEEK_TICKS)
+	} else if key == nui.KeyHome {
+		v.seek(0)
+	} else if key == nui.KeyEnd {
+		v.seek(len(v.Replay.Ticks))
+	}
+}
+
+// Seeks to where the progress bar is clicked.
+func (v *ReplayViewer) Mouse(ev nui.MouseEvent) bool {
+	if ev.Button != nui.MouseLeft || ev.Release || ev.Y != 2 || ev.X < 2 || ev.X >= MAP_WIDTH-2 {
+		return false
+	}
+	v.seek(int(ev.X-2) * len(v.Replay.Ticks) / (MAP_WIDTH - 4))
+	return true
+}
+
+// Serves a viewer of the replay at path to every client
+// that connects, until the context is done.
+func serveReplay(ctx context.Context, srv *nui.Server, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	replay, err := ReadReplay(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("reading replay: %w", err)
+	}
+	if _, err := NewReplayViewer(replay, ""); err != nil {
+		return err
+	}
+	log.Printf("replay of %d players, %d ticks\n", len(replay.Players), len(replay.Ticks))
+
+	var viewers sync.Map /* int => context.CancelFunc */
+	srv.HandleConnect = func(clientID int) {
+		viewer, _ := NewReplayViewer(replay, filepath.Base(path))
+		screen := &nui.Screen{Widgets: []nui.Widget{viewer}}
+		srv.SetScreen(clientID, screen)
+
+		ctx, cancel := context.WithCancel(ctx)
+		viewers.Store(clientID, cancel)
+		go func() {
+			ticker := time.NewTicker(TICK_DURATION)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+
+				screen.Lock()
+				changed := viewer.Advance()
+				screen.Unlock()
+				if changed {
+					srv.Invalidate(clientID)
+				}
+			}
+		}()
+	}
+	srv.HandleDisconnect = func(clientID int) {
+		if cancel, ok := viewers.LoadAndDelete(clientID); ok {
+			cancel.(context.CancelFunc)()
+		}
+	}
+
+	return srv.Run(ctx)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/allen-b1/sus-tux/nui"
+	"github.com/allen-b1/sus-tux/nui/snapshot"
+)
+
+// Size of the screens that the server draws
+const SNAPSHOT_WIDTH, SNAPSHOT_HEIGHT = 128, 36
+
+func TestMain(m *testing.M) {
+	snapshot.Main(m)
+}
+
+// Returns a lobby with a host, a player without a name and a bot.
+func newSnapshotLobby() (*nui.Server, *State) {
+	state := &State{
+		clients: map[int]int{0: 0, 1: 1},
+		players: []Player{{name: "alice"}, {}, {name: "Bot 1", bot: true}},
+	}
+	return nui.NewServer(), state
+}
+
+func TestLobbyScreenSnapshot(t *testing.T) {
+	srv, state := newSnapshotLobby()
+	host, _ := makeLobbyScreen(srv, state, 0)
+	snapshot.MatchScreen(t, "lobby_host", host, SNAPSHOT_WIDTH, SNAPSHOT_HEIGHT)
+	player, _ := makeLobbyScreen(srv, state, 1)
+	snapshot.MatchScreen(t, "lobby_player", player, SNAPSHOT_WIDTH, SNAPSHOT_HEIGHT)
+}
+
+// Returns a game that was played for a little while,
+// with the players walking away from where they start.
+func newSnapshotGame() *State {
+	state := &State{
+		clients: map[int]int{0: 0, 1: 1, 2: 2, 3: 3},
+		players: []Player{{name: "alice"}, {name: "bob"}, {}, {name: "Bot 1", bot: true}},
+	}
+	state.game = NewGame(len(state.players), researchFacility, 1)
+	state.game.Step([]Command{
+		{Player: 1, Type: CMD_MOVE, Direction: [2]int8{1, 0}},
+		{Player: 2, Type: CMD_MOVE, Direction: [2]int8{-1, 0}},
+		{Player: 3, Type: CMD_MOVE, Direction: [2]int8{0, 1}},
+	})
+	for i := 0; i < 20; i++ {
+		state.game.Step(nil)
+	}
+	state.view.Store(state.game.Snapshot())
+	return state
+}
+
+func TestGameScreenSnapshot(t *testing.T) {
+	state := newSnapshotGame()
+	for playerIdx, player := range state.game.Players {
+		name := "game_crewmate"
+		if player.Imposter {
+			name = "game_impostor"
+		} else if playerIdx != 0 {
+			continue
+		}
+		snapshot.MatchScreen(t, name, makeGameScreen(state, playerIdx), SNAPSHOT_WIDTH, SNAPSHOT_HEIGHT)
+	}
+}
+
+func TestMapWidgetSnapshot(t *testing.T) {
+	state := newSnapshotGame()
+	players := state.game.Snapshot().Players
+	// A body, which is shown where the player died
+	players[2].Dead, players[2].Body = true, true
+	players[2].Corpse = [2]uint32{players[2].X - 4, players[2].Y}
+
+	everyone := &MapWidget{
+		Map:     researchFacility,
+		Players: players,
+		CenterX: researchFacility.Width / 2, CenterY: researchFacility.Height() / 2,
+	}
+	buf := nui.NewBuffer(MAP_WIDTH, MAP_HEIGHT, nui.Black)
+	everyone.Draw(buf)
+	snapshot.Match(t, "map_everyone", buf)
+
+	player := &MapWidget{
+		PlayerColor: playerColor(0),
+		Map:         researchFacility,
+		Players:     players,
+		Player:      &players[0],
+	}
+	buf = nui.NewBuffer(MAP_WIDTH, MAP_HEIGHT, nui.Black)
+	player.Draw(buf)
+	snapshot.Match(t, "map_player", buf)
+}
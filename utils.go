@@ -1,6 +1,37 @@
 package main
 
-import "github.com/allen-b1/sus-tux/nui"
+import (
+	"fmt"
+	"net"
+
+	"github.com/allen-b1/sus-tux/nui"
+)
+
+const MAX_PLAYERS = 15
+
+// Distinct colors for each player slot, readable
+// against both the black lobby and the white map floor.
+var playerColors = []nui.Color{
+	nui.RGB(197, 17, 17),   // red
+	nui.RGB(19, 46, 209),   // blue
+	nui.RGB(17, 127, 45),   // green
+	nui.RGB(237, 84, 186),  // pink
+	nui.RGB(239, 125, 13),  // orange
+	nui.RGB(107, 47, 187),  // purple
+	nui.RGB(56, 226, 221),  // cyan
+	nui.RGB(80, 239, 57),   // lime
+	nui.RGB(113, 73, 30),   // brown
+	nui.RGB(214, 180, 0),   // yellow
+	nui.RGB(117, 133, 147), // gray
+	nui.RGB(160, 30, 80),   // maroon
+	nui.RGB(236, 150, 190), // rose
+	nui.RGB(146, 136, 118), // tan
+	nui.RGB(215, 100, 103), // coral
+}
+
+func playerColor(playerIdx int) nui.Color {
+	return playerColors[playerIdx%len(playerColors)]
+}
 
 func ternaryByte(cond bool, iftrue byte, other byte) byte {
 	if cond {
@@ -25,3 +56,22 @@ func ternaryColor(cond bool, t nui.Color, f nui.Color) nui.Color {
 		return f
 	}
 }
+
+// Returns the name of a player, or a placeholder
+// if they did not choose one.
+func displayName(name string, playerIdx int) string {
+	if name == "" {
+		return fmt.Sprintf("Player %d", playerIdx+1)
+	}
+	return name
+}
+
+// Returns the host part of an address, which
+// identifies a client across connections.
+func addrHost(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}